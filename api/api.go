@@ -57,6 +57,11 @@ func (apiServer *APIServer) NewRouter() *web.Service {
 	// Configuration endpoints
 	service.Get("/api/v2/config", api.GetConfig())
 
+	// Push ingestion endpoint (raw lines, NDJSON or JSON array, optionally gzip encoded)
+	if apiServer.Config.Server.Ingest.Enabled {
+		service.Router.Post("/api/v2/ingest/{tenant}/{dataset}", api.Ingest())
+	}
+
 	// API documentation
 	service.Docs("/v2/docs", swgui.New)
 
@@ -29,9 +29,34 @@ type UDPHealthStatus struct {
 }
 
 type UDPListener struct {
-	Port      int    `json:"port"`
-	DatasetID string `json:"dataset_id"`
-	TenantID  string `json:"tenant_id,omitempty"`
+	Port          int    `json:"port"`
+	Path          string `json:"path,omitempty"`
+	DatasetID     string `json:"dataset_id"`
+	TenantID      string `json:"tenant_id,omitempty"`
+	Protocol      string `json:"protocol,omitempty"`
+	Framing       string `json:"framing,omitempty"`
+	TLSClientAuth string `json:"tls_client_auth,omitempty"`
+	Format        string `json:"format,omitempty"`
+	JSONMode      string `json:"json_mode,omitempty"`
+	Host          string `json:"host,omitempty"`
+	Interface     string `json:"interface,omitempty"`
+
+	MulticastGroups []string `json:"multicast_groups,omitempty"`
+	ProxyProtocol   bool     `json:"proxy_protocol,omitempty"`
+
+	// Health only: runtime state of the listener
+	BoundAddresses []string        `json:"bound_addresses,omitempty"`
+	KernelDrops    *int64          `json:"kernel_drops,omitempty"` // Datagrams dropped by the kernel
+	Pipeline       []PipelineStage `json:"pipeline,omitempty"`
+}
+
+// PipelineStage reports the counters of one listener pipeline stage
+type PipelineStage struct {
+	Type      string `json:"type"`
+	Processed int64  `json:"processed"`
+	Dropped   int64  `json:"dropped"`
+	Errors    int64  `json:"errors"`
+	LastError string `json:"last_error,omitempty"`
 }
 
 type ReceiverHealthStatus struct {
@@ -40,15 +65,32 @@ type ReceiverHealthStatus struct {
 }
 
 type ProxyStatsResponse struct {
-	UDPMessagesReceived int64  `json:"udp_messages_received"`
-	UDPMessageErrors    int64  `json:"udp_message_errors"`
-	BatchesCreated      int64  `json:"batches_created"`
-	BatchesForwarded    int64  `json:"batches_forwarded"`
-	ForwardingErrors    int64  `json:"forwarding_errors"`
-	BytesReceived       int64  `json:"bytes_received"`
-	BytesForwarded      int64  `json:"bytes_forwarded"`
-	LastActivity        string `json:"last_activity"`
-	UptimeSeconds       int64  `json:"uptime_seconds"`
+	UDPMessagesReceived     int64  `json:"udp_messages_received"`
+	UDPMessageErrors        int64  `json:"udp_message_errors"`
+	BatchesCreated          int64  `json:"batches_created"`
+	BatchesForwarded        int64  `json:"batches_forwarded"`
+	ForwardingErrors        int64  `json:"forwarding_errors"`
+	BytesReceived           int64  `json:"bytes_received"`
+	BytesForwarded          int64  `json:"bytes_forwarded"`
+	HTTPRecordsReceived     int64  `json:"http_records_received"`
+	HTTPRequestsDenied      int64  `json:"http_requests_denied"`
+	ParseErrors             int64  `json:"parse_errors"`
+	GELFChunkSetsExpired    int64  `json:"gelf_chunk_sets_expired"`
+	GELFChunkErrors         int64  `json:"gelf_chunk_errors"`
+	NetFlowTemplateMisses   int64  `json:"netflow_template_misses"`
+	NetFlowTemplatesEvicted int64  `json:"netflow_templates_evicted"`
+	AcksSent                int64  `json:"acks_sent"`
+	OTLPRecordsReceived     int64  `json:"otlp_records_received"`
+	OTLPRequestsRejected    int64  `json:"otlp_requests_rejected"`
+	SNMPAuthFailures        int64  `json:"snmp_auth_failures"`
+	SNMPInformsUnacked      int64  `json:"snmp_informs_unacknowledged"`
+	MultilineEvents         int64  `json:"multiline_events"`
+	DatagramsTruncated      int64  `json:"datagrams_truncated"`
+	KernelDrops             int64  `json:"kernel_drops"`
+	ProxyProtocolRejected   int64  `json:"proxy_protocol_rejected"`
+	PipelineDropped         int64  `json:"pipeline_dropped"`
+	LastActivity            string `json:"last_activity"`
+	UptimeSeconds           int64  `json:"uptime_seconds"`
 }
 
 // ConfigResponse represents the current system configuration
@@ -71,7 +113,13 @@ type AppConfig struct {
 }
 
 type ServerConfig struct {
-	ApiPort int `json:"api_port"`
+	ApiPort int          `json:"api_port"`
+	Ingest  IngestConfig `json:"ingest"`
+}
+
+type IngestConfig struct {
+	Enabled      bool  `json:"enabled"`
+	MaxBodyBytes int64 `json:"max_body_bytes"`
 }
 
 type UDPConfig struct {
@@ -79,11 +127,15 @@ type UDPConfig struct {
 	Host                string        `json:"host"`
 	Listeners           []UDPListener `json:"listeners"`
 	ReadBufferSizeBytes int           `json:"read_buffer_size_bytes"`
+	MaxDatagramBytes    int           `json:"max_datagram_bytes"`
+	ReusePortReaders    int           `json:"reuseport_readers"`
+	ReadBatchSize       int           `json:"read_batch_size"`
 	MaxBatchLines       int           `json:"max_batch_lines"`
 	MaxBatchBytes       int64         `json:"max_batch_bytes"`
 	BatchTimeoutSeconds int           `json:"batch_timeout_seconds"`
 	CompressionLevel    int           `json:"compression_level"`
 	EnableCompression   bool          `json:"enable_compression"`
+	MaxFrameBytes       int           `json:"max_frame_bytes"`
 }
 
 type ReceiverConfigMasked struct {
@@ -167,6 +219,31 @@ func (api *API) HealthCheck() usecase.Interactor {
 			Status:    udpStatus,
 		}
 
+		// Bound addresses, pipeline and kernel drop counters of the running listeners
+		var kernelDrops int64
+		if api.Services.Listeners != nil {
+			for _, status := range api.Services.Listeners.ListenerStatuses() {
+				if status.Index >= len(output.UDP.Listeners) {
+					continue
+				}
+				listener := &output.UDP.Listeners[status.Index]
+				listener.BoundAddresses = status.BoundAddresses
+				for _, stage := range status.Pipeline {
+					listener.Pipeline = append(listener.Pipeline, PipelineStage{
+						Type:      stage.Type,
+						Processed: stage.Processed,
+						Dropped:   stage.Dropped,
+						Errors:    stage.Errors,
+						LastError: stage.LastError,
+					})
+				}
+				if status.KernelDrops != nil {
+					listener.KernelDrops = status.KernelDrops
+					kernelDrops += *status.KernelDrops
+				}
+			}
+		}
+
 		// Receiver status
 		receiverStatus := "unknown"
 		if cfg.Receiver.BaseURL != "" {
@@ -181,15 +258,32 @@ func (api *API) HealthCheck() usecase.Interactor {
 
 		// Stats
 		output.Stats = ProxyStatsResponse{
-			UDPMessagesReceived: stats.UDPMessagesReceived,
-			UDPMessageErrors:    stats.UDPMessageErrors,
-			BatchesCreated:      stats.BatchesCreated,
-			BatchesForwarded:    stats.BatchesForwarded,
-			ForwardingErrors:    stats.ForwardingErrors,
-			BytesReceived:       stats.BytesReceived,
-			BytesForwarded:      stats.BytesForwarded,
-			LastActivity:        stats.LastActivity.Format(time.RFC3339),
-			UptimeSeconds:       stats.UptimeSeconds,
+			UDPMessagesReceived:     stats.UDPMessagesReceived,
+			UDPMessageErrors:        stats.UDPMessageErrors,
+			BatchesCreated:          stats.BatchesCreated,
+			BatchesForwarded:        stats.BatchesForwarded,
+			ForwardingErrors:        stats.ForwardingErrors,
+			BytesReceived:           stats.BytesReceived,
+			BytesForwarded:          stats.BytesForwarded,
+			HTTPRecordsReceived:     stats.HTTPRecordsReceived,
+			HTTPRequestsDenied:      stats.HTTPRequestsDenied,
+			ParseErrors:             stats.ParseErrors,
+			GELFChunkSetsExpired:    stats.GELFChunkSetsExpired,
+			GELFChunkErrors:         stats.GELFChunkErrors,
+			NetFlowTemplateMisses:   stats.NetFlowTemplateMisses,
+			NetFlowTemplatesEvicted: stats.NetFlowTemplatesEvicted,
+			AcksSent:                stats.AcksSent,
+			OTLPRecordsReceived:     stats.OTLPRecordsReceived,
+			OTLPRequestsRejected:    stats.OTLPRequestsRejected,
+			SNMPAuthFailures:        stats.SNMPAuthFailures,
+			SNMPInformsUnacked:      stats.SNMPInformsUnacked,
+			MultilineEvents:         stats.MultilineEvents,
+			DatagramsTruncated:      stats.DatagramsTruncated,
+			KernelDrops:             kernelDrops,
+			ProxyProtocolRejected:   stats.ProxyProtocolRejected,
+			PipelineDropped:         stats.PipelineDropped,
+			LastActivity:            stats.LastActivity.Format(time.RFC3339),
+			UptimeSeconds:           stats.UptimeSeconds,
 		}
 
 		log.Debugf("Health check completed: status=%s", overallStatus)
@@ -217,6 +311,10 @@ func (api *API) GetConfig() usecase.Interactor {
 		// Server configuration
 		output.Server = ServerConfig{
 			ApiPort: cfg.Server.ApiPort,
+			Ingest: IngestConfig{
+				Enabled:      cfg.Server.Ingest.Enabled,
+				MaxBodyBytes: cfg.Server.Ingest.MaxBodyBytes,
+			},
 		}
 
 		// UDP configuration
@@ -225,11 +323,15 @@ func (api *API) GetConfig() usecase.Interactor {
 			Host:                cfg.UDP.Host,
 			Listeners:           convertListeners(cfg.UDP.Listeners),
 			ReadBufferSizeBytes: cfg.UDP.ReadBufferSizeBytes,
+			MaxDatagramBytes:    cfg.UDP.MaxDatagramBytes,
+			ReusePortReaders:    cfg.UDP.ReusePortReaders,
+			ReadBatchSize:       cfg.UDP.ReadBatchSize,
 			MaxBatchLines:       cfg.UDP.MaxBatchLines,
 			MaxBatchBytes:       cfg.UDP.MaxBatchBytes,
 			BatchTimeoutSeconds: cfg.UDP.BatchTimeoutSeconds,
 			CompressionLevel:    cfg.UDP.CompressionLevel,
 			EnableCompression:   cfg.UDP.EnableCompression,
+			MaxFrameBytes:       cfg.UDP.MaxFrameBytes,
 		}
 
 		// Receiver configuration
@@ -283,10 +385,26 @@ func (api *API) GetConfig() usecase.Interactor {
 func convertListeners(configListeners []config.UDPListener) []UDPListener {
 	listeners := make([]UDPListener, len(configListeners))
 	for i, l := range configListeners {
+		var groups []string
+		for _, group := range l.Multicast {
+			groups = append(groups, group.Group)
+		}
+
 		listeners[i] = UDPListener{
-			Port:      l.Port,
-			DatasetID: l.DatasetID,
-			TenantID:  l.TenantID,
+			Port:          l.Port,
+			Path:          l.Path,
+			DatasetID:     l.DatasetID,
+			TenantID:      l.TenantID,
+			Protocol:      l.Protocol,
+			Framing:       l.Framing,
+			TLSClientAuth: l.TLS.ClientAuth,
+			Format:        l.Format,
+			JSONMode:      l.JSONMode,
+			Host:          l.Host,
+			Interface:     l.Interface,
+
+			MulticastGroups: groups,
+			ProxyProtocol:   l.ProxyProtocol.Enabled,
 		}
 	}
 	return listeners
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/n0needt0/bytefreezer-proxy/domain"
+	"github.com/n0needt0/bytefreezer-proxy/services"
+	"github.com/n0needt0/go-goodies/log"
+)
+
+// IngestResponse represents the result of an ingest request
+type IngestResponse struct {
+	Accepted int    `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Ingest returns a handler that accepts pushed records for a tenant/dataset.
+// The body may be raw lines, NDJSON or a JSON array, optionally gzip encoded.
+func (api *API) Ingest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenantID := chi.URLParam(r, "tenant")
+		datasetID := chi.URLParam(r, "dataset")
+
+		ingestor := api.Services.Ingestor
+		if ingestor == nil {
+			writeIngestResponse(w, http.StatusServiceUnavailable, 0, services.ErrIngestUnavailable.Error())
+			return
+		}
+
+		body := io.Reader(http.MaxBytesReader(w, r.Body, api.Config.Server.Ingest.MaxBodyBytes))
+		if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+			gzipReader, err := gzip.NewReader(body)
+			if err != nil {
+				writeIngestResponse(w, http.StatusBadRequest, 0, "invalid gzip body: "+err.Error())
+				return
+			}
+			defer gzipReader.Close()
+			body = io.LimitReader(gzipReader, api.Config.Server.Ingest.MaxBodyBytes+1)
+		}
+
+		data, err := io.ReadAll(body)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeIngestResponse(w, http.StatusRequestEntityTooLarge, 0, "request body too large")
+			return
+		}
+		if err != nil {
+			writeIngestResponse(w, http.StatusBadRequest, 0, "failed to read body: "+err.Error())
+			return
+		}
+		if int64(len(data)) > api.Config.Server.Ingest.MaxBodyBytes {
+			writeIngestResponse(w, http.StatusRequestEntityTooLarge, 0, "request body too large")
+			return
+		}
+
+		records, err := splitIngestRecords(r.Header.Get("Content-Type"), data)
+		if err != nil {
+			writeIngestResponse(w, http.StatusBadRequest, 0, err.Error())
+			return
+		}
+
+		now := time.Now()
+		msgs := make([]*domain.UDPMessage, 0, len(records))
+		for _, record := range records {
+			msgs = append(msgs, &domain.UDPMessage{
+				Data:      record,
+				From:      r.RemoteAddr,
+				Timestamp: now,
+				TenantID:  tenantID,
+				DatasetID: datasetID,
+			})
+		}
+
+		accepted, err := ingestor.Ingest(msgs)
+		for _, msg := range msgs[:accepted] {
+			api.Services.ProxyStats.HTTPRecordsReceived++
+			api.Services.ProxyStats.BytesReceived += int64(len(msg.Data))
+		}
+		if accepted > 0 {
+			api.Services.ProxyStats.LastActivity = now
+		}
+		if err != nil {
+			api.Services.ProxyStats.HTTPRequestsDenied++
+			status := http.StatusServiceUnavailable
+			if errors.Is(err, services.ErrIngestQueueFull) {
+				status = http.StatusTooManyRequests
+				w.Header().Set("Retry-After", "1")
+			}
+			log.Warnf("Ingest for %s/%s rejected after %d of %d records: %v",
+				tenantID, datasetID, accepted, len(records), err)
+			writeIngestResponse(w, status, accepted, err.Error())
+			return
+		}
+
+		writeIngestResponse(w, http.StatusAccepted, accepted, "")
+	}
+}
+
+// splitIngestRecords splits a request body into individual records, validating all of
+// them before any is queued. JSON bodies may hold a single object, an array or NDJSON;
+// anything else is split by line, and NDJSON lines must each be valid JSON.
+func splitIngestRecords(contentType string, data []byte) ([][]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	trimmed := bytes.TrimSpace(data)
+
+	if mediaType == "application/json" && json.Valid(trimmed) {
+		if trimmed[0] != '[' {
+			return [][]byte{trimmed}, nil
+		}
+
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, errors.New("invalid JSON array: " + err.Error())
+		}
+
+		records := make([][]byte, 0, len(items))
+		for _, item := range items {
+			records = append(records, []byte(item))
+		}
+		return records, nil
+	}
+
+	records, err := splitLines(data)
+	if err != nil {
+		return nil, err
+	}
+	if mediaType == "application/json" || mediaType == "application/x-ndjson" {
+		if len(records) == 0 {
+			return nil, errors.New("invalid JSON body")
+		}
+		for i, record := range records {
+			if !json.Valid(record) {
+				return nil, fmt.Errorf("invalid JSON in record %d", i+1)
+			}
+		}
+	}
+	return records, nil
+}
+
+// splitLines returns the non-blank lines of a body with surrounding whitespace removed
+func splitLines(data []byte) ([][]byte, error) {
+	var records [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		record := make([]byte, len(line))
+		copy(record, line)
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// writeIngestResponse writes the JSON ingest result
+func writeIngestResponse(w http.ResponseWriter, status, accepted int, errMsg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(IngestResponse{Accepted: accepted, Error: errMsg}); err != nil {
+		log.Debugf("Failed to write ingest response: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitIngestRecords(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:        "json object",
+			contentType: "application/json",
+			body:        " {\"a\":1}\n",
+			want:        []string{`{"a":1}`},
+		},
+		{
+			name:        "json array",
+			contentType: "application/json; charset=utf-8",
+			body:        `[{"a":1}, {"b":2}]`,
+			want:        []string{`{"a":1}`, `{"b":2}`},
+		},
+		{
+			name:        "ndjson as application/json",
+			contentType: "application/json",
+			body:        "{\"a\":1}\n\n{\"b\":2}\n",
+			want:        []string{`{"a":1}`, `{"b":2}`},
+		},
+		{
+			name:        "ndjson as application/json with an invalid record",
+			contentType: "application/json",
+			body:        "{\"a\":1}\n{\"b\":\n",
+			wantErr:     true,
+		},
+		{
+			name:        "empty application/json",
+			contentType: "application/json",
+			body:        " \n",
+			wantErr:     true,
+		},
+		{
+			name:        "ndjson",
+			contentType: "application/x-ndjson",
+			body:        "{\"a\":1}\r\n[2]\n",
+			want:        []string{`{"a":1}`, `[2]`},
+		},
+		{
+			name:        "ndjson with an invalid record",
+			contentType: "application/x-ndjson",
+			body:        "{\"a\":1}\nnot json\n",
+			wantErr:     true,
+		},
+		{
+			name:        "raw lines",
+			contentType: "text/plain",
+			body:        "first line\n  \nsecond line",
+			want:        []string{"first line", "second line"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := splitIngestRecords(tt.contentType, []byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitIngestRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, record := range records {
+				got = append(got, string(record))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
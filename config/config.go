@@ -44,18 +44,29 @@ type LoggingConfig struct {
 }
 
 type Server struct {
-	ApiPort int `mapstructure:"api_port"`
+	ApiPort int    `mapstructure:"api_port"`
+	Ingest  Ingest `mapstructure:"ingest"`
+}
+
+// Ingest configures the HTTP push ingestion endpoint
+type Ingest struct {
+	Enabled      bool  `mapstructure:"enabled"`
+	MaxBodyBytes int64 `mapstructure:"max_body_bytes"` // Max decompressed request body size
 }
 
 type UDP struct {
 	Enabled             bool          `mapstructure:"enabled"`
 	Host                string        `mapstructure:"host"`
-	ReadBufferSizeBytes int           `mapstructure:"read_buffer_size_bytes"`
+	ReadBufferSizeBytes int           `mapstructure:"read_buffer_size_bytes"` // Kernel socket receive buffer (SO_RCVBUF)
+	MaxDatagramBytes    int           `mapstructure:"max_datagram_bytes"`     // Size of pooled read buffers; longer datagrams are truncated
+	ReusePortReaders    int           `mapstructure:"reuseport_readers"`      // Linux only: >0 reads each UDP port with this many SO_REUSEPORT sockets using recvmmsg
+	ReadBatchSize       int           `mapstructure:"read_batch_size"`        // Datagrams per recvmmsg call, default 64
 	MaxBatchLines       int           `mapstructure:"max_batch_lines"`
 	MaxBatchBytes       int64         `mapstructure:"max_batch_bytes"`
 	BatchTimeoutSeconds int           `mapstructure:"batch_timeout_seconds"`
 	CompressionLevel    int           `mapstructure:"compression_level"`
 	EnableCompression   bool          `mapstructure:"enable_compression"`
+	MaxFrameBytes       int           `mapstructure:"max_frame_bytes"` // Max size of a single stream (TCP) frame
 	Listeners           []UDPListener `mapstructure:"listeners"`
 }
 
@@ -63,6 +74,157 @@ type UDPListener struct {
 	Port      int    `mapstructure:"port"`
 	DatasetID string `mapstructure:"dataset_id"`
 	TenantID  string `mapstructure:"tenant_id,omitempty"` // Optional: override global tenant
+	Protocol  string `mapstructure:"protocol"`            // Optional: "udp" (default), "tcp", "tls", "forward", "lumberjack", "unix", "unixgram", "file", "otlp_grpc" or "otlp_http"
+	Framing   string `mapstructure:"framing"`             // Stream only: "auto" (default), "octet_counting" or "lf"
+	Format    string `mapstructure:"format"`              // Optional: "raw" (default), "syslog", "cef", "leef", "kv", "csv", "gelf", "netflow", "sflow" or "snmp"
+	JSONMode  string `mapstructure:"json_mode"`           // Optional: "normalize" (default), "compact" or "validate_only"
+	TLS       TLS    `mapstructure:"tls"`                 // TLS only: certificates and client auth
+	Host      string `mapstructure:"host"`                // Optional: bind address overriding udp.host ("::" binds dual-stack)
+	Interface string `mapstructure:"interface"`           // Optional, Linux only: bind to this network interface (SO_BINDTODEVICE)
+
+	Path        string `mapstructure:"path"`         // Unix only: socket path (replaces port)
+	SocketMode  string `mapstructure:"socket_mode"`  // Unix only: octal file mode, default "0660"
+	SocketOwner string `mapstructure:"socket_owner"` // Unix only: "user[:group]" names or numeric IDs
+
+	Paths []string `mapstructure:"paths"` // File only: glob patterns of files to tail
+
+	GELFChunkTimeoutSeconds int        `mapstructure:"gelf_chunk_timeout_seconds"` // GELF only: drop incomplete chunk sets after this long
+	TagRules                []TagRule  `mapstructure:"tag_rules"`                  // Forward only: map Fluentd tags to datasets
+	Lumberjack              Lumberjack `mapstructure:"lumberjack"`                 // Lumberjack only: window limits
+	OTLP                    OTLP       `mapstructure:"otlp"`                       // OTLP only: resource attribute routing
+	SNMP                    SNMP       `mapstructure:"snmp"`                       // SNMP only: communities, v3 users and MIB names
+	Multiline               Multiline  `mapstructure:"multiline"`                  // UDP/unixgram only: join multi-datagram events per sender
+	KV                      KV         `mapstructure:"kv"`                         // Format kv only: separators
+	CSV                     CSV        `mapstructure:"csv"`                        // Format csv only: columns and delimiter
+
+	Multicast     []MulticastGroup `mapstructure:"multicast"`      // UDP only: multicast groups to join
+	ProxyProtocol ProxyProtocol    `mapstructure:"proxy_protocol"` // UDP and stream only: PROXY protocol v2 headers from load balancers
+
+	Pipeline []Stage `mapstructure:"pipeline"` // Optional: processing stages run on each message before batching
+}
+
+// Stage is one step of a listener pipeline. Type selects the stage; the other
+// settings apply to the types noted beside them.
+type Stage struct {
+	Type           string        `mapstructure:"type"`             // "parse", "rename", "drop", "add_fields", "redact", "route" or "timestamp"
+	Field          string        `mapstructure:"field"`            // parse, drop, route: field to read (default "message"); timestamp: default "timestamp"
+	Pattern        string        `mapstructure:"pattern"`          // drop, route: regexp the field must match; redact: text to mask; parse regex/grok: expression to match
+	Patterns       []string      `mapstructure:"patterns"`         // parse regex/grok: further expressions, tried in order until one matches
+	PatternFiles   []string      `mapstructure:"pattern_files"`    // parse grok: files of "NAME regexp" definitions added to the bundled library
+	Format         string        `mapstructure:"format"`           // parse: "json" (default), "syslog", "cef", "leef", "kv", "csv", "regex" or "grok"
+	KV             KV            `mapstructure:"kv"`               // parse kv: separators
+	CSV            CSV           `mapstructure:"csv"`              // parse csv: columns and delimiter
+	Target         string        `mapstructure:"target"`           // parse: store the parsed fields under this field instead of the top level; timestamp: field to write (default "@timestamp")
+	Renames        []FieldRename `mapstructure:"renames"`          // rename: fields to move
+	Values         []FieldValue  `mapstructure:"values"`           // add_fields: fields to set
+	Fields         []string      `mapstructure:"fields"`           // redact: fields to mask, default every string in the record
+	Replacement    string        `mapstructure:"replacement"`      // redact: mask text, default "[REDACTED]"
+	DatasetID      string        `mapstructure:"dataset_id"`       // route: dataset for matching messages
+	TenantID       string        `mapstructure:"tenant_id"`        // route: tenant for matching messages
+	Layouts        []string      `mapstructure:"layouts"`          // timestamp: layouts tried in order, default ["rfc3339", "unix"]
+	Timezone       string        `mapstructure:"timezone"`         // timestamp: zone of times without an offset, default UTC
+	MaxSkewSeconds int           `mapstructure:"max_skew_seconds"` // timestamp: flag event times further than this from the receive time
+}
+
+// KV configures key=value parsing
+type KV struct {
+	FieldSplit string `mapstructure:"field_split"` // Between pairs, default " "
+	ValueSplit string `mapstructure:"value_split"` // Between key and value, default "="
+}
+
+// CSV configures CSV parsing
+type CSV struct {
+	Columns   []string `mapstructure:"columns"`   // Field names in column order
+	Delimiter string   `mapstructure:"delimiter"` // One character, default ","
+}
+
+// FieldRename moves a field; dots in names address nested objects
+type FieldRename struct {
+	From string `mapstructure:"from"`
+	To   string `mapstructure:"to"`
+}
+
+// FieldValue sets a field to a fixed string
+type FieldValue struct {
+	Field string `mapstructure:"field"`
+	Value string `mapstructure:"value"`
+}
+
+// ProxyProtocol requires a PROXY protocol v2 header on every datagram or connection
+// and reports the client address it carries instead of the load balancer's
+type ProxyProtocol struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	TrustedCIDRs []string `mapstructure:"trusted_cidrs"` // Senders allowed to send headers; everything else is rejected
+}
+
+// MulticastGroup is a multicast group joined by a UDP listener
+type MulticastGroup struct {
+	Group     string   `mapstructure:"group"`     // IPv4 or IPv6 group address
+	Sources   []string `mapstructure:"sources"`   // Optional: only accept these sources (source-specific multicast)
+	Interface string   `mapstructure:"interface"` // Optional: interface to join on, default the listener interface or the system choice
+}
+
+// Multiline joins consecutive datagrams from the same sender into one record.
+// A line starts a new event when it matches StartPattern; otherwise it is appended
+// to the sender's current event if it matches ContinuationPattern (or, when only
+// StartPattern is set, always).
+type Multiline struct {
+	StartPattern        string `mapstructure:"start_pattern"`         // Regexp matching the first line of an event
+	ContinuationPattern string `mapstructure:"continuation_pattern"`  // Regexp matching lines that continue an event
+	MaxLines            int    `mapstructure:"max_lines"`             // Emit the event after this many lines, default 500
+	FlushTimeoutSeconds int    `mapstructure:"flush_timeout_seconds"` // Emit the event after the sender is idle this long, default 2
+	MaxPendingSenders   int    `mapstructure:"max_pending_senders"`   // Senders with an open event; the longest idle is emitted beyond this, default 10000
+}
+
+// SNMP configures trap decoding for listeners with format "snmp"
+type SNMP struct {
+	Communities []string   `mapstructure:"communities"` // Accepted v1/v2c communities; empty accepts any
+	Users       []SNMPUser `mapstructure:"users"`       // SNMPv3 USM users
+	MIBFile     string     `mapstructure:"mib_file"`    // Optional "name oid" mapping file
+}
+
+// SNMPUser is an SNMPv3 USM user allowed to send traps
+type SNMPUser struct {
+	Username       string `mapstructure:"username"`
+	AuthProtocol   string `mapstructure:"auth_protocol"` // "md5", "sha", "sha224", "sha256", "sha384" or "sha512"
+	AuthPassphrase string `mapstructure:"auth_passphrase"`
+	PrivProtocol   string `mapstructure:"priv_protocol"` // "des" or "aes"
+	PrivPassphrase string `mapstructure:"priv_passphrase"`
+}
+
+// OTLP routes OTLP log records by resource attribute; records without the
+// attribute use the listener tenant/dataset
+type OTLP struct {
+	TenantAttribute  string `mapstructure:"tenant_attribute"`
+	DatasetAttribute string `mapstructure:"dataset_attribute"` // Default "service.namespace"
+}
+
+// Lumberjack bounds the windows a Beats client may send before they are acknowledged
+type Lumberjack struct {
+	MaxWindowEvents int   `mapstructure:"max_window_events"` // Default 16384
+	MaxWindowBytes  int64 `mapstructure:"max_window_bytes"`  // Event bytes per window, default 64MB
+}
+
+// TagRule routes Fluent Forward events whose tag matches a Fluentd-style pattern
+type TagRule struct {
+	Match     string `mapstructure:"match"` // e.g. "app.*" or "kube.**"
+	DatasetID string `mapstructure:"dataset_id"`
+	TenantID  string `mapstructure:"tenant_id"`
+}
+
+type TLS struct {
+	CertFile         string               `mapstructure:"cert_file"`
+	KeyFile          string               `mapstructure:"key_file"`
+	CAFile           string               `mapstructure:"ca_file"`           // CA bundle used to verify client certificates
+	ClientAuth       string               `mapstructure:"client_auth"`       // "none" (default), "request" or "require"
+	IdentityMappings []TLSIdentityMapping `mapstructure:"identity_mappings"` // Require ca_file; only verified certificates are mapped
+}
+
+// TLSIdentityMapping overrides tenant/dataset for clients presenting a matching certificate
+type TLSIdentityMapping struct {
+	Identity  string `mapstructure:"identity"` // Certificate CN or SAN (DNS, email, URI or IP)
+	TenantID  string `mapstructure:"tenant_id"`
+	DatasetID string `mapstructure:"dataset_id"`
 }
 
 type Receiver struct {
@@ -136,9 +298,45 @@ func LoadConfig(cfgFile, envPrefix string, cfg *Config) error {
 	if cfg.UDP.ReadBufferSizeBytes == 0 {
 		cfg.UDP.ReadBufferSizeBytes = 65536 // 64KB default
 	}
+	if cfg.UDP.MaxDatagramBytes == 0 {
+		cfg.UDP.MaxDatagramBytes = 65535 // Largest UDP payload
+	}
+	if cfg.UDP.ReadBatchSize == 0 {
+		cfg.UDP.ReadBatchSize = 64
+	}
 	if cfg.UDP.CompressionLevel == 0 {
 		cfg.UDP.CompressionLevel = 6 // Default gzip compression level
 	}
+	if cfg.UDP.MaxFrameBytes == 0 {
+		cfg.UDP.MaxFrameBytes = 1048576 // 1MB default
+	}
+	for i := range cfg.UDP.Listeners {
+		if cfg.UDP.Listeners[i].GELFChunkTimeoutSeconds == 0 {
+			cfg.UDP.Listeners[i].GELFChunkTimeoutSeconds = 5
+		}
+		if cfg.UDP.Listeners[i].Lumberjack.MaxWindowEvents == 0 {
+			cfg.UDP.Listeners[i].Lumberjack.MaxWindowEvents = 16384
+		}
+		if cfg.UDP.Listeners[i].Lumberjack.MaxWindowBytes == 0 {
+			cfg.UDP.Listeners[i].Lumberjack.MaxWindowBytes = 67108864 // 64MB default
+		}
+		if cfg.UDP.Listeners[i].OTLP.DatasetAttribute == "" {
+			cfg.UDP.Listeners[i].OTLP.DatasetAttribute = "service.namespace"
+		}
+		if cfg.UDP.Listeners[i].Multiline.MaxLines == 0 {
+			cfg.UDP.Listeners[i].Multiline.MaxLines = 500
+		}
+		if cfg.UDP.Listeners[i].Multiline.FlushTimeoutSeconds == 0 {
+			cfg.UDP.Listeners[i].Multiline.FlushTimeoutSeconds = 2
+		}
+		if cfg.UDP.Listeners[i].Multiline.MaxPendingSenders == 0 {
+			cfg.UDP.Listeners[i].Multiline.MaxPendingSenders = 10000
+		}
+	}
+
+	if cfg.Server.Ingest.MaxBodyBytes == 0 {
+		cfg.Server.Ingest.MaxBodyBytes = 10485760 // 10MB default
+	}
 
 	// Spooling defaults
 	if cfg.Spooling.Directory == "" {
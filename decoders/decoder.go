@@ -0,0 +1,38 @@
+// Package decoders turns binary or framed datagrams into JSON records.
+package decoders
+
+import (
+	"net"
+	"time"
+)
+
+// Decoder turns a single datagram into zero or more JSON records.
+// Stateful decoders (chunk reassembly, template caches) may return no
+// records until enough datagrams have been seen.
+type Decoder interface {
+	Decode(data []byte, from net.Addr, now time.Time) ([][]byte, error)
+}
+
+// Expirer is implemented by decoders holding state that must be aged out
+type Expirer interface {
+	// Expire drops stale state and returns the number of entries removed
+	Expire(now time.Time) int
+}
+
+// sourceHost returns the IP (or path) portion of a sender address
+func sourceHost(from net.Addr) string {
+	switch addr := from.(type) {
+	case *net.UDPAddr:
+		return addr.IP.String()
+	case *net.TCPAddr:
+		return addr.IP.String()
+	case nil:
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(from.String())
+	if err != nil {
+		return from.String()
+	}
+	return host
+}
@@ -0,0 +1,239 @@
+package decoders
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// fluentEventTimeExt is the MessagePack extension type of Fluentd EventTime
+const fluentEventTimeExt = 0
+
+// ForwardEvent is a single Fluentd event
+type ForwardEvent struct {
+	Time   time.Time
+	Record map[string]interface{}
+}
+
+// ForwardMessage is one decoded Fluent Forward protocol message
+type ForwardMessage struct {
+	Tag    string
+	Events []ForwardEvent
+	Chunk  string // Set when the client expects an ack
+}
+
+// DecodeForwardMessage converts a decoded MessagePack array into a Forward message.
+// It accepts Message, Forward, PackedForward and CompressedPackedForward modes.
+func DecodeForwardMessage(v interface{}, maxBytes int) (*ForwardMessage, error) {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) < 2 {
+		return nil, errors.New("forward message is not an array of at least 2 elements")
+	}
+
+	tag, ok := msgpackString(arr[0])
+	if !ok {
+		return nil, errors.New("forward message tag is not a string")
+	}
+	msg := &ForwardMessage{Tag: tag}
+
+	switch entries := arr[1].(type) {
+	case []interface{}:
+		// Forward mode: [tag, [[time, record], ...], option?]
+		for _, entry := range entries {
+			event, err := decodeForwardEntry(entry)
+			if err != nil {
+				return nil, err
+			}
+			msg.Events = append(msg.Events, event)
+		}
+		msg.Chunk = forwardOption(arr, 2, "chunk")
+
+	case string, []byte:
+		// PackedForward / CompressedPackedForward mode: [tag, bin, option?]
+		packed, _ := msgpackBytes(entries)
+		if forwardOption(arr, 2, "compressed") == "gzip" {
+			var err error
+			if packed, err = gunzipLimited(packed, maxBytes); err != nil {
+				return nil, err
+			}
+		}
+
+		dec := NewMsgpackDecoder(bufio.NewReader(bytes.NewReader(packed)), maxBytes)
+		for {
+			entry, err := dec.Decode()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid packed forward entries: %w", err)
+			}
+			event, err := decodeForwardEntry(entry)
+			if err != nil {
+				return nil, err
+			}
+			msg.Events = append(msg.Events, event)
+		}
+		msg.Chunk = forwardOption(arr, 2, "chunk")
+
+	default:
+		// Message mode: [tag, time, record, option?]
+		if len(arr) < 3 {
+			return nil, errors.New("forward message mode requires time and record")
+		}
+		event, err := decodeForwardEntry(arr[1:3])
+		if err != nil {
+			return nil, err
+		}
+		msg.Events = append(msg.Events, event)
+		msg.Chunk = forwardOption(arr, 3, "chunk")
+	}
+
+	return msg, nil
+}
+
+// ForwardEventJSON encodes an event record as JSON, adding "tag" and "time"
+// unless the record already carries those keys
+func ForwardEventJSON(tag string, event ForwardEvent) ([]byte, error) {
+	record := make(map[string]interface{}, len(event.Record)+2)
+	for k, v := range event.Record {
+		record[k] = jsonValue(v)
+	}
+	if _, exists := record["tag"]; !exists {
+		record["tag"] = tag
+	}
+	if _, exists := record["time"]; !exists {
+		record["time"] = event.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return json.Marshal(record)
+}
+
+// decodeForwardEntry decodes a [time, record] pair
+func decodeForwardEntry(v interface{}) (ForwardEvent, error) {
+	pair, ok := v.([]interface{})
+	if !ok || len(pair) < 2 {
+		return ForwardEvent{}, errors.New("forward entry is not a [time, record] pair")
+	}
+
+	ts, err := forwardTime(pair[0])
+	if err != nil {
+		return ForwardEvent{}, err
+	}
+
+	record, ok := pair[1].(map[string]interface{})
+	if !ok {
+		return ForwardEvent{}, errors.New("forward entry record is not a map")
+	}
+
+	return ForwardEvent{Time: ts, Record: record}, nil
+}
+
+// forwardTime converts an integer, float or EventTime extension into a time
+func forwardTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case int64:
+		return time.Unix(t, 0), nil
+	case uint64:
+		return time.Unix(int64(t), 0), nil
+	case float64:
+		sec, frac := math.Modf(t)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	case MsgpackExt:
+		if t.Type == fluentEventTimeExt && len(t.Data) == 8 {
+			return time.Unix(int64(binary.BigEndian.Uint32(t.Data)), int64(binary.BigEndian.Uint32(t.Data[4:]))), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid forward event time %v", v)
+}
+
+// forwardOption returns a string option from the trailing option map, if present
+func forwardOption(arr []interface{}, index int, key string) string {
+	if len(arr) <= index {
+		return ""
+	}
+	options, ok := arr[index].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	value, _ := msgpackString(options[key])
+	return value
+}
+
+// jsonValue converts decoded MessagePack values into JSON-friendly values
+func jsonValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case []byte:
+		return string(value)
+	case MsgpackExt:
+		if ts, err := forwardTime(value); err == nil {
+			return ts.UTC().Format(time.RFC3339Nano)
+		}
+		return hex.EncodeToString(value.Data)
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, item := range value {
+			out[i] = jsonValue(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			out[k] = jsonValue(item)
+		}
+		return out
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil
+		}
+	}
+	return v
+}
+
+func msgpackString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case []byte:
+		return string(s), true
+	}
+	return "", false
+}
+
+func msgpackBytes(v interface{}) ([]byte, bool) {
+	switch b := v.(type) {
+	case []byte:
+		return b, true
+	case string:
+		return []byte(b), true
+	}
+	return nil, false
+}
+
+// gunzipLimited decompresses gzip data, rejecting output larger than maxBytes
+func gunzipLimited(data []byte, maxBytes int) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip entries: %w", err)
+	}
+	defer reader.Close()
+
+	limit := int64(maxBytes)
+	if limit <= 0 {
+		limit = 1 << 30
+	}
+	out, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip entries: %w", err)
+	}
+	if int64(len(out)) > limit {
+		return nil, errors.New("decompressed forward entries exceed maximum size")
+	}
+	return out, nil
+}
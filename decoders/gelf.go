@@ -0,0 +1,185 @@
+package decoders
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// GELF chunking constants (https://go2docs.graylog.org/current/getting_in_log_data/gelf.html)
+const (
+	gelfChunkHeaderLen = 12
+	gelfMaxChunks      = 128
+	gelfMaxPendingSets = 4096
+)
+
+var (
+	gelfChunkMagic = []byte{0x1e, 0x0f}
+
+	// ErrGELFTooLarge is returned when a decompressed GELF message exceeds the size limit
+	ErrGELFTooLarge = errors.New("gelf message exceeds maximum size")
+	// ErrGELFChunk is wrapped by errors for malformed, duplicate or overflowing chunks
+	ErrGELFChunk = errors.New("invalid gelf chunk")
+)
+
+// gelfChunkSet collects the chunks of a single chunked GELF message
+type gelfChunkSet struct {
+	chunks    [][]byte
+	received  int
+	size      int
+	firstSeen time.Time
+}
+
+// GELFDecoder reassembles chunked GELF datagrams and decompresses payloads
+type GELFDecoder struct {
+	timeout  time.Duration
+	maxBytes int
+
+	mu        sync.Mutex
+	pending   map[[8]byte]*gelfChunkSet
+	lastSweep time.Time
+}
+
+// NewGELFDecoder creates a GELF decoder. Incomplete chunk sets are dropped after timeout,
+// and decompressed messages larger than maxBytes are rejected.
+func NewGELFDecoder(timeout time.Duration, maxBytes int) *GELFDecoder {
+	return &GELFDecoder{
+		timeout:  timeout,
+		maxBytes: maxBytes,
+		pending:  make(map[[8]byte]*gelfChunkSet),
+	}
+}
+
+// Decode processes a single datagram. It returns the GELF JSON payload once a
+// message is complete, or no records while chunks of a message are still outstanding.
+func (d *GELFDecoder) Decode(data []byte, from net.Addr, now time.Time) ([][]byte, error) {
+	if bytes.HasPrefix(data, gelfChunkMagic) {
+		complete, err := d.addChunk(data, now)
+		if err != nil || complete == nil {
+			return nil, err
+		}
+		data = complete
+	}
+
+	payload, err := d.decompress(data)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{payload}, nil
+}
+
+// Expire drops chunk sets that have been incomplete for longer than the timeout.
+// Sweeps run at most once per second and return the number of sets dropped.
+func (d *GELFDecoder) Expire(now time.Time) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now.Sub(d.lastSweep) < time.Second {
+		return 0
+	}
+	d.lastSweep = now
+
+	expired := 0
+	for id, set := range d.pending {
+		if now.Sub(set.firstSeen) > d.timeout {
+			delete(d.pending, id)
+			expired++
+		}
+	}
+	return expired
+}
+
+// addChunk stores a chunk and returns the reassembled payload when all chunks have arrived
+func (d *GELFDecoder) addChunk(data []byte, now time.Time) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(data) < gelfChunkHeaderLen {
+		return nil, fmt.Errorf("%w: header truncated", ErrGELFChunk)
+	}
+
+	var id [8]byte
+	copy(id[:], data[2:10])
+	seq := int(data[10])
+	count := int(data[11])
+
+	if count == 0 || count > gelfMaxChunks || seq >= count {
+		return nil, fmt.Errorf("%w: sequence %d of %d", ErrGELFChunk, seq, count)
+	}
+
+	set, exists := d.pending[id]
+	if !exists {
+		if len(d.pending) >= gelfMaxPendingSets {
+			return nil, fmt.Errorf("%w: too many incomplete messages", ErrGELFChunk)
+		}
+		set = &gelfChunkSet{
+			chunks:    make([][]byte, count),
+			firstSeen: now,
+		}
+		d.pending[id] = set
+	}
+
+	if len(set.chunks) != count {
+		return nil, fmt.Errorf("%w: count mismatch (%d != %d)", ErrGELFChunk, count, len(set.chunks))
+	}
+	if set.chunks[seq] != nil {
+		return nil, fmt.Errorf("%w: duplicate sequence %d", ErrGELFChunk, seq)
+	}
+
+	payload := make([]byte, len(data)-gelfChunkHeaderLen)
+	copy(payload, data[gelfChunkHeaderLen:])
+	set.chunks[seq] = payload
+	set.received++
+	set.size += len(payload)
+
+	if d.maxBytes > 0 && set.size > d.maxBytes {
+		delete(d.pending, id)
+		return nil, ErrGELFTooLarge
+	}
+
+	if set.received < count {
+		return nil, nil
+	}
+
+	delete(d.pending, id)
+	return bytes.Join(set.chunks, nil), nil
+}
+
+// decompress inflates zlib or gzip payloads; uncompressed payloads are returned as-is
+func (d *GELFDecoder) decompress(data []byte) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+
+	switch {
+	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+	case len(data) >= 2 && data[0] == 0x78 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0:
+		reader, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open compressed gelf payload: %w", err)
+	}
+	defer reader.Close()
+
+	limit := int64(d.maxBytes)
+	if limit <= 0 {
+		limit = 1 << 30
+	}
+	decompressed, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress gelf payload: %w", err)
+	}
+	if int64(len(decompressed)) > limit {
+		return nil, ErrGELFTooLarge
+	}
+
+	return decompressed, nil
+}
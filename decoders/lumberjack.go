@@ -0,0 +1,244 @@
+package decoders
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Lumberjack v2 frame types
+const (
+	lumberjackVersion    = '2'
+	lumberjackWindow     = 'W'
+	lumberjackCompressed = 'C'
+	lumberjackJSON       = 'J'
+	lumberjackData       = 'D'
+	lumberjackAck        = 'A'
+)
+
+// lumberjackMaxDepth bounds compressed frames nested inside compressed frames
+const lumberjackMaxDepth = 2
+
+// ErrLumberjackProtocol is returned for malformed Lumberjack frames
+var ErrLumberjackProtocol = errors.New("invalid lumberjack frame")
+
+// LumberjackBatch is one window of events sent by a Beats client
+type LumberjackBatch struct {
+	Events  [][]byte // JSON encoded events
+	LastSeq uint32   // Sequence number to acknowledge once the window is delivered
+	size    int64    // Total bytes of Events
+}
+
+// LumberjackReader reads Lumberjack v2 windows from a stream
+type LumberjackReader struct {
+	r               *bufio.Reader
+	maxBytes        int   // Upper bound for payloads and decompressed frames
+	maxWindowEvents int   // Upper bound for the events announced by a window
+	maxWindowBytes  int64 // Upper bound for the event bytes of a window
+}
+
+// NewLumberjackReader creates a reader for a Beats connection. A window is held in
+// memory until it is acknowledged, so windows above maxWindowEvents events or
+// maxWindowBytes bytes are rejected; zero disables a limit.
+func NewLumberjackReader(r *bufio.Reader, maxBytes, maxWindowEvents int, maxWindowBytes int64) *LumberjackReader {
+	return &LumberjackReader{r: r, maxBytes: maxBytes, maxWindowEvents: maxWindowEvents, maxWindowBytes: maxWindowBytes}
+}
+
+// ReadBatch reads a window frame followed by the number of events it announces.
+// Events may be sent as JSON or key/value data frames, optionally zlib compressed.
+func (lr *LumberjackReader) ReadBatch() (*LumberjackBatch, error) {
+	version, frameType, err := readLumberjackHeader(lr.r)
+	if err != nil {
+		return nil, err
+	}
+	if version != lumberjackVersion || frameType != lumberjackWindow {
+		return nil, fmt.Errorf("%w: expected window frame, got version %q type %q", ErrLumberjackProtocol, version, frameType)
+	}
+
+	var window uint32
+	if err := binary.Read(lr.r, binary.BigEndian, &window); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if lr.maxWindowEvents > 0 && window > uint32(lr.maxWindowEvents) {
+		return nil, fmt.Errorf("%w: window of %d events exceeds limit %d", ErrLumberjackProtocol, window, lr.maxWindowEvents)
+	}
+
+	batch := &LumberjackBatch{Events: make([][]byte, 0, min(window, 4096))}
+	for uint32(len(batch.Events)) < window {
+		if err := lr.readFrame(lr.r, batch, 0); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+	if uint32(len(batch.Events)) > window {
+		// Compressed frames may carry more events than announced
+		return nil, fmt.Errorf("%w: window of %d events carried %d", ErrLumberjackProtocol, window, len(batch.Events))
+	}
+	return batch, nil
+}
+
+// add appends an event to the batch, enforcing the window byte limit
+func (lr *LumberjackReader) add(batch *LumberjackBatch, event []byte, seq uint32) error {
+	batch.size += int64(len(event))
+	if lr.maxWindowBytes > 0 && batch.size > lr.maxWindowBytes {
+		return fmt.Errorf("%w: window exceeds %d bytes", ErrLumberjackProtocol, lr.maxWindowBytes)
+	}
+	batch.Events = append(batch.Events, event)
+	batch.LastSeq = seq
+	return nil
+}
+
+// readFrame reads one data, JSON or compressed frame into the batch
+func (lr *LumberjackReader) readFrame(r *bufio.Reader, batch *LumberjackBatch, depth int) error {
+	version, frameType, err := readLumberjackHeader(r)
+	if err != nil {
+		return err
+	}
+	if version != lumberjackVersion && !(version == '1' && frameType == lumberjackData) {
+		return fmt.Errorf("%w: unsupported version %q", ErrLumberjackProtocol, version)
+	}
+
+	switch frameType {
+	case lumberjackJSON:
+		seq, err := readUint32(r)
+		if err != nil {
+			return err
+		}
+		payload, err := lr.readPayload(r)
+		if err != nil {
+			return err
+		}
+		if err := lr.add(batch, payload, seq); err != nil {
+			return err
+		}
+
+	case lumberjackData:
+		seq, err := readUint32(r)
+		if err != nil {
+			return err
+		}
+		pairs, err := readUint32(r)
+		if err != nil {
+			return err
+		}
+		fields := make(map[string]string, min(pairs, 1024))
+		for i := uint32(0); i < pairs; i++ {
+			key, err := lr.readPayload(r)
+			if err != nil {
+				return err
+			}
+			value, err := lr.readPayload(r)
+			if err != nil {
+				return err
+			}
+			fields[string(key)] = string(value)
+		}
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		if err := lr.add(batch, data, seq); err != nil {
+			return err
+		}
+
+	case lumberjackCompressed:
+		if depth >= lumberjackMaxDepth {
+			return fmt.Errorf("%w: compressed frames nested too deeply", ErrLumberjackProtocol)
+		}
+		payload, err := lr.readPayload(r)
+		if err != nil {
+			return err
+		}
+		inflated, err := inflateLimited(payload, lr.maxBytes)
+		if err != nil {
+			return err
+		}
+		inner := bufio.NewReader(bytes.NewReader(inflated))
+		for {
+			if _, err := inner.Peek(1); errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err := lr.readFrame(inner, batch, depth+1); err != nil {
+				return unexpectedEOF(err)
+			}
+		}
+
+	default:
+		return fmt.Errorf("%w: unexpected frame type %q", ErrLumberjackProtocol, frameType)
+	}
+
+	return nil
+}
+
+// readPayload reads a length-prefixed payload
+func (lr *LumberjackReader) readPayload(r *bufio.Reader) ([]byte, error) {
+	n, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if lr.maxBytes > 0 && n > uint32(lr.maxBytes) {
+		return nil, fmt.Errorf("%w: payload of %d bytes exceeds limit %d", ErrLumberjackProtocol, n, lr.maxBytes)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// EncodeLumberjackAck encodes an ACK frame for a sequence number.
+// An ACK for sequence 0 acts as a keepalive while a window is still being delivered.
+func EncodeLumberjackAck(seq uint32) []byte {
+	out := []byte{lumberjackVersion, lumberjackAck}
+	return binary.BigEndian.AppendUint32(out, seq)
+}
+
+func readLumberjackHeader(r *bufio.Reader) (byte, byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, 0, err
+	}
+	return header[0], header[1], nil
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b[:]), nil
+}
+
+// unexpectedEOF reports a connection closed in the middle of a window as truncated
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// inflateLimited decompresses zlib data, rejecting output larger than maxBytes
+func inflateLimited(data []byte, maxBytes int) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid compressed frame: %v", ErrLumberjackProtocol, err)
+	}
+	defer reader.Close()
+
+	limit := int64(maxBytes)
+	if limit <= 0 {
+		limit = 1 << 30
+	}
+	out, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid compressed frame: %v", ErrLumberjackProtocol, err)
+	}
+	if int64(len(out)) > limit {
+		return nil, fmt.Errorf("%w: decompressed frame exceeds maximum size", ErrLumberjackProtocol)
+	}
+	return out, nil
+}
@@ -0,0 +1,261 @@
+package decoders
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// MsgpackExt is a MessagePack extension value (e.g. Fluentd EventTime)
+type MsgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+// MsgpackReader is the input required by the MessagePack decoder
+type MsgpackReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// MsgpackDecoder decodes MessagePack values into Go values:
+// nil, bool, int64, uint64, float64, string, []byte, []interface{},
+// map[string]interface{} and MsgpackExt.
+type MsgpackDecoder struct {
+	r        MsgpackReader
+	maxBytes int // Upper bound for any single str/bin/ext or container length
+}
+
+// NewMsgpackDecoder creates a decoder reading from r. maxBytes bounds individual
+// lengths so a malicious header cannot force huge allocations.
+func NewMsgpackDecoder(r MsgpackReader, maxBytes int) *MsgpackDecoder {
+	return &MsgpackDecoder{r: r, maxBytes: maxBytes}
+}
+
+// Decode reads the next complete value
+func (d *MsgpackDecoder) Decode() (interface{}, error) {
+	return d.decode(0)
+}
+
+const msgpackMaxDepth = 64
+
+func (d *MsgpackDecoder) decode(depth int) (interface{}, error) {
+	if depth > msgpackMaxDepth {
+		return nil, errors.New("msgpack value nested too deeply")
+	}
+
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0x80 && c <= 0x8f:
+		return d.decodeMap(int(c&0x0f), depth)
+	case c >= 0x90 && c <= 0x9f:
+		return d.decodeArray(int(c&0x0f), depth)
+	case c >= 0xa0 && c <= 0xbf:
+		b, err := d.readBytes(int(c & 0x1f))
+		return string(b), err
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readLength(c - 0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return d.readBytes(n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readLength(c - 0xc7)
+		if err != nil {
+			return nil, err
+		}
+		return d.readExt(n)
+	case 0xca:
+		b, err := d.readBytes(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 0xcb:
+		b, err := d.readBytes(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		b, err := d.readBytes(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return readUint(b), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		b, err := d.readBytes(1 << (c - 0xd0))
+		if err != nil {
+			return nil, err
+		}
+		return readInt(b), nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.readExt(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readLength(c - 0xd9)
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.readBytes(n)
+		return string(b), err
+	case 0xdc, 0xdd:
+		n, err := d.readLength(c - 0xdc + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.readLength(c - 0xde + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n, depth)
+	}
+
+	return nil, fmt.Errorf("invalid msgpack type byte 0x%02x", c)
+}
+
+// readLength reads a 1, 2 or 4 byte length (size class 0, 1 or 2)
+func (d *MsgpackDecoder) readLength(sizeClass byte) (int, error) {
+	b, err := d.readBytes(1 << sizeClass)
+	if err != nil {
+		return 0, err
+	}
+	n := readUint(b)
+	if d.maxBytes > 0 && n > uint64(d.maxBytes) {
+		return 0, fmt.Errorf("msgpack length %d exceeds limit %d", n, d.maxBytes)
+	}
+	return int(n), nil
+}
+
+func (d *MsgpackDecoder) readBytes(n int) ([]byte, error) {
+	if d.maxBytes > 0 && n > d.maxBytes {
+		return nil, fmt.Errorf("msgpack length %d exceeds limit %d", n, d.maxBytes)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+func (d *MsgpackDecoder) readExt(n int) (interface{}, error) {
+	t, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.readBytes(n)
+	if err != nil {
+		return nil, err
+	}
+	return MsgpackExt{Type: int8(t), Data: data}, nil
+}
+
+func (d *MsgpackDecoder) decodeArray(n, depth int) (interface{}, error) {
+	items := make([]interface{}, 0, min(n, 1024))
+	for i := 0; i < n; i++ {
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+func (d *MsgpackDecoder) decodeMap(n, depth int) (interface{}, error) {
+	m := make(map[string]interface{}, min(n, 1024))
+	for i := 0; i < n; i++ {
+		k, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[msgpackKey(k)] = v
+	}
+	return m, nil
+}
+
+// msgpackKey converts a map key into a JSON object key
+func msgpackKey(k interface{}) string {
+	switch key := k.(type) {
+	case string:
+		return key
+	case []byte:
+		return string(key)
+	case int64:
+		return strconv.FormatInt(key, 10)
+	case uint64:
+		return strconv.FormatUint(key, 10)
+	default:
+		return fmt.Sprint(key)
+	}
+}
+
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, x := range b {
+		v = v<<8 | uint64(x)
+	}
+	return v
+}
+
+func readInt(b []byte) int64 {
+	v := int64(int8(b[0]))
+	for _, x := range b[1:] {
+		v = v<<8 | int64(x)
+	}
+	return v
+}
+
+// EncodeMsgpackMap encodes a flat map of string values (e.g. a Forward protocol ack)
+func EncodeMsgpackMap(m map[string]string) []byte {
+	out := []byte{0x80 | byte(len(m))}
+	for k, v := range m {
+		out = appendMsgpackString(out, k)
+		out = appendMsgpackString(out, v)
+	}
+	return out
+}
+
+func appendMsgpackString(out []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		out = append(out, 0xa0|byte(n))
+	case n < 256:
+		out = append(out, 0xd9, byte(n))
+	case n < 65536:
+		out = append(out, 0xda)
+		out = binary.BigEndian.AppendUint16(out, uint16(n))
+	default:
+		out = append(out, 0xdb)
+		out = binary.BigEndian.AppendUint32(out, uint32(n))
+	}
+	return append(out, s...)
+}
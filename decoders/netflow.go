@@ -0,0 +1,663 @@
+package decoders
+
+import (
+	"container/list"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// NetFlow / IPFIX protocol versions
+const (
+	netflowV5 = 5
+	netflowV9 = 9
+	ipfixV10  = 10
+)
+
+// Flow set IDs reserved for templates
+const (
+	netflowV9TemplateSetID        = 0
+	netflowV9OptionsTemplateSetID = 1
+	ipfixTemplateSetID            = 2
+	ipfixOptionsTemplateSetID     = 3
+	minDataSetID                  = 256
+)
+
+const (
+	netflowV5HeaderLen = 24
+	netflowV5RecordLen = 48
+	netflowV9HeaderLen = 20
+	ipfixHeaderLen     = 16
+	flowSetHeaderLen   = 4
+	ipfixVarLength     = 65535
+	ipfixEnterpriseBit = 0x8000
+)
+
+// Template cache limits. Exporters are identified by their (spoofable) source
+// address, so the cache is bounded and templates that are not used or refreshed expire.
+const (
+	netflowMaxTemplates = 10000
+	netflowTemplateTTL  = 30 * time.Minute
+)
+
+var (
+	// ErrNetFlowTemplateMissing is wrapped when data sets arrive before their template
+	ErrNetFlowTemplateMissing = errors.New("netflow template not yet received")
+	// errNetFlowTruncated is returned for packets shorter than their headers claim
+	errNetFlowTruncated = errors.New("netflow packet truncated")
+)
+
+// flowField describes one field of a v9/IPFIX template
+type flowField struct {
+	id         uint16
+	length     uint16
+	enterprise uint32
+}
+
+// flowTemplate is a cached v9/IPFIX template
+type flowTemplate struct {
+	key      templateKey
+	fields   []flowField
+	options  bool // Options template: data records describe the exporter, not flows
+	lastUsed time.Time
+}
+
+// templateKey scopes templates per exporter, version and observation domain (source ID)
+type templateKey struct {
+	exporter   string
+	version    uint16
+	domain     uint32
+	templateID uint16
+}
+
+// NetFlowDecoder decodes NetFlow v5, v9 and IPFIX packets into one JSON record per flow
+type NetFlowDecoder struct {
+	mu        sync.Mutex
+	templates map[templateKey]*list.Element
+	order     *list.List // Templates by last use, most recent first
+	evicted   int        // Templates evicted for space since the last Expire
+	lastSweep time.Time
+}
+
+// NewNetFlowDecoder creates a NetFlow/IPFIX decoder with an empty template cache
+func NewNetFlowDecoder() *NetFlowDecoder {
+	return &NetFlowDecoder{
+		templates: make(map[templateKey]*list.Element),
+		order:     list.New(),
+	}
+}
+
+// Expire drops templates idle for longer than netflowTemplateTTL and returns how many
+// were removed, including those evicted since the last call to stay within netflowMaxTemplates
+func (d *NetFlowDecoder) Expire(now time.Time) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	removed := d.evicted
+	d.evicted = 0
+	if now.Sub(d.lastSweep) < time.Second {
+		return removed
+	}
+	d.lastSweep = now
+
+	for element := d.order.Back(); element != nil; element = d.order.Back() {
+		template := element.Value.(*flowTemplate)
+		if now.Sub(template.lastUsed) <= netflowTemplateTTL {
+			break
+		}
+		d.removeTemplate(element)
+		removed++
+	}
+	return removed
+}
+
+// storeTemplate caches a template, evicting the least recently used one when full.
+// The caller holds d.mu.
+func (d *NetFlowDecoder) storeTemplate(template *flowTemplate) {
+	if element, ok := d.templates[template.key]; ok {
+		element.Value = template
+		d.order.MoveToFront(element)
+		return
+	}
+	d.templates[template.key] = d.order.PushFront(template)
+	if d.order.Len() > netflowMaxTemplates {
+		d.removeTemplate(d.order.Back())
+		d.evicted++
+	}
+}
+
+// removeTemplate drops a cached template. The caller holds d.mu.
+func (d *NetFlowDecoder) removeTemplate(element *list.Element) {
+	d.order.Remove(element)
+	delete(d.templates, element.Value.(*flowTemplate).key)
+}
+
+// Decode decodes a NetFlow v5/v9 or IPFIX packet. Records decoded before an
+// error (such as a data set without a cached template) are still returned.
+func (d *NetFlowDecoder) Decode(data []byte, from net.Addr, now time.Time) ([][]byte, error) {
+	if len(data) < 2 {
+		return nil, errNetFlowTruncated
+	}
+
+	exporter := sourceHost(from)
+	switch version := binary.BigEndian.Uint16(data); version {
+	case netflowV5:
+		return d.decodeV5(data, exporter)
+	case netflowV9:
+		return d.decodeV9(data, exporter, now)
+	case ipfixV10:
+		return d.decodeIPFIX(data, exporter, now)
+	default:
+		return nil, fmt.Errorf("unsupported netflow version %d", version)
+	}
+}
+
+// decodeV5 decodes a fixed-format NetFlow v5 packet
+func (d *NetFlowDecoder) decodeV5(data []byte, exporter string) ([][]byte, error) {
+	if len(data) < netflowV5HeaderLen {
+		return nil, errNetFlowTruncated
+	}
+
+	count := int(binary.BigEndian.Uint16(data[2:]))
+	sysUptime := binary.BigEndian.Uint32(data[4:])
+	exportTime := time.Unix(int64(binary.BigEndian.Uint32(data[8:])), int64(binary.BigEndian.Uint32(data[12:])))
+	sequence := binary.BigEndian.Uint32(data[16:])
+	engineType := data[20]
+	engineID := data[21]
+	samplingInterval := binary.BigEndian.Uint16(data[22:]) & 0x3fff
+
+	if len(data) < netflowV5HeaderLen+count*netflowV5RecordLen {
+		return nil, errNetFlowTruncated
+	}
+
+	records := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		r := data[netflowV5HeaderLen+i*netflowV5RecordLen:]
+		record := map[string]interface{}{
+			"flow_version":      netflowV5,
+			"exporter":          exporter,
+			"sequence":          sequence,
+			"export_time":       exportTime.UTC().Format(time.RFC3339Nano),
+			"engine_type":       engineType,
+			"engine_id":         engineID,
+			"sampling_interval": samplingInterval,
+			"src_addr":          net.IP(r[0:4]).String(),
+			"dst_addr":          net.IP(r[4:8]).String(),
+			"next_hop":          net.IP(r[8:12]).String(),
+			"input_snmp":        binary.BigEndian.Uint16(r[12:]),
+			"output_snmp":       binary.BigEndian.Uint16(r[14:]),
+			"packets":           binary.BigEndian.Uint32(r[16:]),
+			"bytes":             binary.BigEndian.Uint32(r[20:]),
+			"first_switched":    uptimeToTime(exportTime, sysUptime, binary.BigEndian.Uint32(r[24:])),
+			"last_switched":     uptimeToTime(exportTime, sysUptime, binary.BigEndian.Uint32(r[28:])),
+			"src_port":          binary.BigEndian.Uint16(r[32:]),
+			"dst_port":          binary.BigEndian.Uint16(r[34:]),
+			"tcp_flags":         r[37],
+			"protocol":          r[38],
+			"tos":               r[39],
+			"src_as":            binary.BigEndian.Uint16(r[40:]),
+			"dst_as":            binary.BigEndian.Uint16(r[42:]),
+			"src_mask":          r[44],
+			"dst_mask":          r[45],
+		}
+
+		jsonBytes, err := json.Marshal(record)
+		if err != nil {
+			return records, err
+		}
+		records = append(records, jsonBytes)
+	}
+
+	return records, nil
+}
+
+// packetHeader carries v9/IPFIX header values shared by every record in a packet
+type packetHeader struct {
+	version    uint16
+	exporter   string
+	exportTime time.Time
+	sysUptime  uint32 // v9 only
+	sequence   uint32
+	domain     uint32
+	received   time.Time // Refreshes the templates the packet uses
+}
+
+// decodeV9 decodes a template-based NetFlow v9 packet
+func (d *NetFlowDecoder) decodeV9(data []byte, exporter string, now time.Time) ([][]byte, error) {
+	if len(data) < netflowV9HeaderLen {
+		return nil, errNetFlowTruncated
+	}
+
+	header := packetHeader{
+		version:    netflowV9,
+		exporter:   exporter,
+		sysUptime:  binary.BigEndian.Uint32(data[4:]),
+		exportTime: time.Unix(int64(binary.BigEndian.Uint32(data[8:])), 0),
+		sequence:   binary.BigEndian.Uint32(data[12:]),
+		domain:     binary.BigEndian.Uint32(data[16:]),
+		received:   now,
+	}
+
+	return d.decodeSets(data[netflowV9HeaderLen:], header)
+}
+
+// decodeIPFIX decodes an IPFIX (NetFlow v10) message
+func (d *NetFlowDecoder) decodeIPFIX(data []byte, exporter string, now time.Time) ([][]byte, error) {
+	if len(data) < ipfixHeaderLen {
+		return nil, errNetFlowTruncated
+	}
+
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if length < ipfixHeaderLen || length > len(data) {
+		return nil, errNetFlowTruncated
+	}
+
+	header := packetHeader{
+		version:    ipfixV10,
+		exporter:   exporter,
+		exportTime: time.Unix(int64(binary.BigEndian.Uint32(data[4:])), 0),
+		sequence:   binary.BigEndian.Uint32(data[8:]),
+		domain:     binary.BigEndian.Uint32(data[12:]),
+		received:   now,
+	}
+
+	return d.decodeSets(data[ipfixHeaderLen:length], header)
+}
+
+// decodeSets walks the flow sets of a v9/IPFIX packet, caching templates and decoding data sets
+func (d *NetFlowDecoder) decodeSets(data []byte, header packetHeader) ([][]byte, error) {
+	var records [][]byte
+	var missing []uint16
+
+	for len(data) >= flowSetHeaderLen {
+		setID := binary.BigEndian.Uint16(data)
+		setLen := int(binary.BigEndian.Uint16(data[2:]))
+		if setLen < flowSetHeaderLen || setLen > len(data) {
+			return records, errNetFlowTruncated
+		}
+		body := data[flowSetHeaderLen:setLen]
+		data = data[setLen:]
+
+		var err error
+		switch {
+		case header.version == netflowV9 && setID == netflowV9TemplateSetID,
+			header.version == ipfixV10 && setID == ipfixTemplateSetID:
+			err = d.parseTemplates(body, header, false)
+		case header.version == netflowV9 && setID == netflowV9OptionsTemplateSetID,
+			header.version == ipfixV10 && setID == ipfixOptionsTemplateSetID:
+			err = d.parseTemplates(body, header, true)
+		case setID >= minDataSetID:
+			var setRecords [][]byte
+			setRecords, err = d.decodeDataSet(body, setID, header)
+			if errors.Is(err, ErrNetFlowTemplateMissing) {
+				missing = append(missing, setID)
+				err = nil
+			}
+			records = append(records, setRecords...)
+		}
+		if err != nil {
+			return records, err
+		}
+	}
+
+	if len(missing) > 0 {
+		return records, fmt.Errorf("%w: exporter %s domain %d templates %v",
+			ErrNetFlowTemplateMissing, header.exporter, header.domain, missing)
+	}
+	return records, nil
+}
+
+// parseTemplates parses a (options) template set and updates the template cache
+func (d *NetFlowDecoder) parseTemplates(data []byte, header packetHeader, options bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for len(data) >= 4 {
+		templateID := binary.BigEndian.Uint16(data)
+		fieldCount := int(binary.BigEndian.Uint16(data[2:]))
+		data = data[4:]
+
+		if templateID < minDataSetID {
+			// Remaining bytes are set padding
+			return nil
+		}
+
+		key := templateKey{
+			exporter:   header.exporter,
+			version:    header.version,
+			domain:     header.domain,
+			templateID: templateID,
+		}
+
+		if options && header.version == netflowV9 {
+			// v9 options templates carry scope and option lengths in bytes
+			if len(data) < 2 {
+				return errNetFlowTruncated
+			}
+			scopeLen := fieldCount
+			optionLen := int(binary.BigEndian.Uint16(data))
+			data = data[2:]
+			fieldCount = (scopeLen + optionLen) / 4
+		} else if options {
+			// IPFIX options templates add a scope field count
+			if len(data) < 2 {
+				return errNetFlowTruncated
+			}
+			data = data[2:]
+		}
+
+		if fieldCount == 0 {
+			// IPFIX template withdrawal
+			if element, ok := d.templates[key]; ok {
+				d.removeTemplate(element)
+			}
+			continue
+		}
+
+		fields := make([]flowField, 0, fieldCount)
+		for i := 0; i < fieldCount; i++ {
+			if len(data) < 4 {
+				return errNetFlowTruncated
+			}
+			field := flowField{
+				id:     binary.BigEndian.Uint16(data),
+				length: binary.BigEndian.Uint16(data[2:]),
+			}
+			data = data[4:]
+
+			if header.version == ipfixV10 && field.id&ipfixEnterpriseBit != 0 {
+				if len(data) < 4 {
+					return errNetFlowTruncated
+				}
+				field.id &^= ipfixEnterpriseBit
+				field.enterprise = binary.BigEndian.Uint32(data)
+				data = data[4:]
+			}
+			fields = append(fields, field)
+		}
+
+		d.storeTemplate(&flowTemplate{key: key, fields: fields, options: options, lastUsed: header.received})
+	}
+
+	return nil
+}
+
+// decodeDataSet decodes the records of a data set using its cached template
+func (d *NetFlowDecoder) decodeDataSet(data []byte, setID uint16, header packetHeader) ([][]byte, error) {
+	d.mu.Lock()
+	element, ok := d.templates[templateKey{
+		exporter:   header.exporter,
+		version:    header.version,
+		domain:     header.domain,
+		templateID: setID,
+	}]
+	if !ok {
+		d.mu.Unlock()
+		return nil, ErrNetFlowTemplateMissing
+	}
+	d.order.MoveToFront(element)
+	template := element.Value.(*flowTemplate)
+	template.lastUsed = header.received
+	d.mu.Unlock()
+
+	minLen := 0
+	for _, field := range template.fields {
+		if field.length != ipfixVarLength {
+			minLen += int(field.length)
+		} else {
+			minLen++
+		}
+	}
+	if minLen == 0 {
+		return nil, fmt.Errorf("netflow template %d has no fields", setID)
+	}
+
+	var records [][]byte
+	// Sets are padded with zero bytes, which may be longer than a short record
+	for len(data) >= minLen && !allZero(data) {
+		record, rest, err := decodeFlowRecord(data, template, header)
+		if err != nil {
+			return records, err
+		}
+		data = rest
+
+		if template.options {
+			// Options records describe the exporter (e.g. sampling) rather than flows
+			continue
+		}
+
+		record["template_id"] = setID
+		jsonBytes, err := json.Marshal(record)
+		if err != nil {
+			return records, err
+		}
+		records = append(records, jsonBytes)
+	}
+
+	return records, nil
+}
+
+// decodeFlowRecord decodes a single data record and returns the remaining bytes
+func decodeFlowRecord(data []byte, template *flowTemplate, header packetHeader) (map[string]interface{}, []byte, error) {
+	record := map[string]interface{}{
+		"flow_version": header.version,
+		"exporter":     header.exporter,
+		"sequence":     header.sequence,
+		"export_time":  header.exportTime.UTC().Format(time.RFC3339Nano),
+	}
+	if header.version == netflowV9 {
+		record["source_id"] = header.domain
+	} else {
+		record["observation_domain_id"] = header.domain
+	}
+
+	for _, field := range template.fields {
+		length := int(field.length)
+		if field.length == ipfixVarLength {
+			if len(data) < 1 {
+				return nil, nil, errNetFlowTruncated
+			}
+			length = int(data[0])
+			data = data[1:]
+			if length == 255 {
+				if len(data) < 2 {
+					return nil, nil, errNetFlowTruncated
+				}
+				length = int(binary.BigEndian.Uint16(data))
+				data = data[2:]
+			}
+		}
+		if len(data) < length {
+			return nil, nil, errNetFlowTruncated
+		}
+
+		name, value := flowFieldValue(field, data[:length], header)
+		record[name] = value
+		data = data[length:]
+	}
+
+	return record, data, nil
+}
+
+// flowFieldValue names a field and converts its raw bytes to a JSON-friendly value
+func flowFieldValue(field flowField, raw []byte, header packetHeader) (string, interface{}) {
+	if field.enterprise != 0 {
+		return fmt.Sprintf("field_%d_%d", field.enterprise, field.id), formatUnknown(raw)
+	}
+
+	info, known := flowFields[field.id]
+	if !known {
+		return fmt.Sprintf("field_%d", field.id), formatUnknown(raw)
+	}
+
+	switch info.kind {
+	case kindIP:
+		if len(raw) == net.IPv4len || len(raw) == net.IPv6len {
+			return info.name, net.IP(raw).String()
+		}
+	case kindMAC:
+		if len(raw) == 6 {
+			return info.name, net.HardwareAddr(raw).String()
+		}
+	case kindString:
+		return info.name, string(trimNul(raw))
+	case kindUptime:
+		if header.version == netflowV9 && len(raw) == 4 {
+			return info.name, uptimeToTime(header.exportTime, header.sysUptime, binary.BigEndian.Uint32(raw))
+		}
+	case kindSeconds:
+		if len(raw) == 4 {
+			return info.name, time.Unix(int64(binary.BigEndian.Uint32(raw)), 0).UTC().Format(time.RFC3339Nano)
+		}
+	case kindMillis:
+		if len(raw) == 8 {
+			return info.name, time.UnixMilli(int64(binary.BigEndian.Uint64(raw))).UTC().Format(time.RFC3339Nano)
+		}
+	}
+
+	return info.name, formatUnknown(raw)
+}
+
+// formatUnknown returns integers for up to 8 bytes and hex strings otherwise
+func formatUnknown(raw []byte) interface{} {
+	if len(raw) == 0 || len(raw) > 8 {
+		return hex.EncodeToString(raw)
+	}
+	var value uint64
+	for _, b := range raw {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+// uptimeToTime converts a sysUptime-relative timestamp (ms) into an absolute RFC 3339 time
+func uptimeToTime(exportTime time.Time, sysUptime, uptimeMs uint32) string {
+	offset := time.Duration(int64(sysUptime)-int64(uptimeMs)) * time.Millisecond
+	return exportTime.Add(-offset).UTC().Format(time.RFC3339Nano)
+}
+
+// trimNul strips trailing NUL padding from fixed-length string fields
+func trimNul(raw []byte) []byte {
+	for len(raw) > 0 && raw[len(raw)-1] == 0 {
+		raw = raw[:len(raw)-1]
+	}
+	return raw
+}
+
+// Field value kinds
+const (
+	kindNumber = iota
+	kindIP
+	kindMAC
+	kindString
+	kindUptime
+	kindSeconds
+	kindMillis
+)
+
+type flowFieldInfo struct {
+	name string
+	kind int
+}
+
+// flowFields maps common NetFlow v9 / IPFIX information element IDs to record keys.
+// Names match the NetFlow v5 record keys where the meaning is the same.
+var flowFields = map[uint16]flowFieldInfo{
+	1:   {"bytes", kindNumber},
+	2:   {"packets", kindNumber},
+	3:   {"flows", kindNumber},
+	4:   {"protocol", kindNumber},
+	5:   {"tos", kindNumber},
+	6:   {"tcp_flags", kindNumber},
+	7:   {"src_port", kindNumber},
+	8:   {"src_addr", kindIP},
+	9:   {"src_mask", kindNumber},
+	10:  {"input_snmp", kindNumber},
+	11:  {"dst_port", kindNumber},
+	12:  {"dst_addr", kindIP},
+	13:  {"dst_mask", kindNumber},
+	14:  {"output_snmp", kindNumber},
+	15:  {"next_hop", kindIP},
+	16:  {"src_as", kindNumber},
+	17:  {"dst_as", kindNumber},
+	18:  {"bgp_next_hop", kindIP},
+	19:  {"mul_dst_packets", kindNumber},
+	20:  {"mul_dst_bytes", kindNumber},
+	21:  {"last_switched", kindUptime},
+	22:  {"first_switched", kindUptime},
+	23:  {"out_bytes", kindNumber},
+	24:  {"out_packets", kindNumber},
+	25:  {"min_packet_length", kindNumber},
+	26:  {"max_packet_length", kindNumber},
+	27:  {"src_addr", kindIP},
+	28:  {"dst_addr", kindIP},
+	29:  {"src_mask", kindNumber},
+	30:  {"dst_mask", kindNumber},
+	31:  {"flow_label", kindNumber},
+	32:  {"icmp_type", kindNumber},
+	33:  {"igmp_type", kindNumber},
+	34:  {"sampling_interval", kindNumber},
+	35:  {"sampling_algorithm", kindNumber},
+	36:  {"flow_active_timeout", kindNumber},
+	37:  {"flow_inactive_timeout", kindNumber},
+	38:  {"engine_type", kindNumber},
+	39:  {"engine_id", kindNumber},
+	40:  {"total_bytes_exported", kindNumber},
+	41:  {"total_packets_exported", kindNumber},
+	42:  {"total_flows_exported", kindNumber},
+	46:  {"mpls_top_label_type", kindNumber},
+	47:  {"mpls_top_label_addr", kindIP},
+	48:  {"sampler_id", kindNumber},
+	49:  {"sampler_mode", kindNumber},
+	50:  {"sampler_random_interval", kindNumber},
+	52:  {"min_ttl", kindNumber},
+	53:  {"max_ttl", kindNumber},
+	54:  {"fragment_id", kindNumber},
+	55:  {"dst_tos", kindNumber},
+	56:  {"src_mac", kindMAC},
+	57:  {"dst_mac", kindMAC},
+	58:  {"src_vlan", kindNumber},
+	59:  {"dst_vlan", kindNumber},
+	60:  {"ip_version", kindNumber},
+	61:  {"direction", kindNumber},
+	62:  {"next_hop", kindIP},
+	63:  {"bgp_next_hop", kindIP},
+	64:  {"ipv6_option_headers", kindNumber},
+	70:  {"mpls_label_1", kindNumber},
+	80:  {"in_dst_mac", kindMAC},
+	81:  {"out_src_mac", kindMAC},
+	82:  {"if_name", kindString},
+	83:  {"if_desc", kindString},
+	85:  {"total_bytes", kindNumber},
+	86:  {"total_packets", kindNumber},
+	89:  {"forwarding_status", kindNumber},
+	136: {"flow_end_reason", kindNumber},
+	148: {"flow_id", kindNumber},
+	150: {"flow_start", kindSeconds},
+	151: {"flow_end", kindSeconds},
+	152: {"flow_start", kindMillis},
+	153: {"flow_end", kindMillis},
+	176: {"icmp_type", kindNumber},
+	177: {"icmp_code", kindNumber},
+	225: {"post_nat_src_addr", kindIP},
+	226: {"post_nat_dst_addr", kindIP},
+	227: {"post_napt_src_port", kindNumber},
+	228: {"post_napt_dst_port", kindNumber},
+	234: {"ingress_vrf_id", kindNumber},
+	235: {"egress_vrf_id", kindNumber},
+}
+
+// allZero reports whether the remaining bytes of a set are padding
+func allZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
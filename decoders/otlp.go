@@ -0,0 +1,221 @@
+package decoders
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// otlpJSONIDFields are the LogRecord fields that OTLP/JSON encodes as hex
+var otlpJSONIDFields = []string{"traceId", "trace_id", "spanId", "span_id"}
+
+// OTLPLogRecord is a single OTLP LogRecord flattened into a JSON line
+type OTLPLogRecord struct {
+	Data     []byte
+	Resource map[string]interface{} // Resource attributes, used for routing
+}
+
+// otlpLogJSON is the NDJSON layout of a flattened LogRecord
+type otlpLogJSON struct {
+	Timestamp         string                 `json:"timestamp,omitempty"`
+	ObservedTimestamp string                 `json:"observed_timestamp,omitempty"`
+	SeverityNumber    int32                  `json:"severity_number,omitempty"`
+	SeverityText      string                 `json:"severity_text,omitempty"`
+	Body              interface{}            `json:"body,omitempty"`
+	Attributes        map[string]interface{} `json:"attributes,omitempty"`
+	TraceID           string                 `json:"trace_id,omitempty"`
+	SpanID            string                 `json:"span_id,omitempty"`
+	Flags             uint32                 `json:"flags,omitempty"`
+	DroppedAttributes uint32                 `json:"dropped_attributes_count,omitempty"`
+	Resource          map[string]interface{} `json:"resource,omitempty"`
+	Scope             *otlpScopeJSON         `json:"scope,omitempty"`
+}
+
+type otlpScopeJSON struct {
+	Name       string                 `json:"name,omitempty"`
+	Version    string                 `json:"version,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// FlattenOTLPLogs converts an OTLP export request into one JSON record per LogRecord,
+// each carrying its resource and scope attributes. It also returns the number of
+// records that could not be encoded.
+func FlattenOTLPLogs(req *collogspb.ExportLogsServiceRequest) ([]OTLPLogRecord, int) {
+	var records []OTLPLogRecord
+	rejected := 0
+
+	for _, resourceLogs := range req.GetResourceLogs() {
+		resource := otlpAttributes(resourceLogs.GetResource().GetAttributes())
+
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			scope := otlpScope(scopeLogs.GetScope())
+
+			for _, logRecord := range scopeLogs.GetLogRecords() {
+				data, err := json.Marshal(otlpLogRecord(logRecord, resource, scope))
+				if err != nil {
+					rejected++
+					continue
+				}
+				records = append(records, OTLPLogRecord{Data: data, Resource: resource})
+			}
+		}
+	}
+
+	return records, rejected
+}
+
+// UnmarshalOTLPLogsJSON decodes an OTLP/JSON logs export request. OTLP/JSON encodes
+// trace and span IDs as hex instead of the base64 protobuf JSON uses for bytes, so
+// they are converted before the request is unmarshalled.
+func UnmarshalOTLPLogsJSON(data []byte, req *collogspb.ExportLogsServiceRequest) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var body map[string]interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return err
+	}
+
+	for _, resourceLogs := range jsonObjects(body, "resourceLogs", "resource_logs") {
+		for _, scopeLogs := range jsonObjects(resourceLogs, "scopeLogs", "scope_logs") {
+			for _, logRecord := range jsonObjects(scopeLogs, "logRecords", "log_records") {
+				for _, field := range otlpJSONIDFields {
+					if err := hexToBase64(logRecord, field); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	converted, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(converted, req)
+}
+
+// jsonObjects returns the objects in the array under either name of a field
+func jsonObjects(object map[string]interface{}, names ...string) []map[string]interface{} {
+	var objects []map[string]interface{}
+	for _, name := range names {
+		items, _ := object[name].([]interface{})
+		for _, item := range items {
+			if child, ok := item.(map[string]interface{}); ok {
+				objects = append(objects, child)
+			}
+		}
+	}
+	return objects
+}
+
+// hexToBase64 re-encodes a hex string field as base64
+func hexToBase64(object map[string]interface{}, field string) error {
+	value, ok := object[field].(string)
+	if !ok || value == "" {
+		return nil
+	}
+	raw, err := hex.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", field, value, err)
+	}
+	object[field] = base64.StdEncoding.EncodeToString(raw)
+	return nil
+}
+
+// OTLPAttributeString returns a resource attribute as a string, if it is set
+func OTLPAttributeString(attributes map[string]interface{}, key string) string {
+	if key == "" {
+		return ""
+	}
+	value, _ := attributes[key].(string)
+	return value
+}
+
+func otlpLogRecord(logRecord *logspb.LogRecord, resource map[string]interface{}, scope *otlpScopeJSON) otlpLogJSON {
+	return otlpLogJSON{
+		Timestamp:         otlpTime(logRecord.GetTimeUnixNano()),
+		ObservedTimestamp: otlpTime(logRecord.GetObservedTimeUnixNano()),
+		SeverityNumber:    int32(logRecord.GetSeverityNumber()),
+		SeverityText:      logRecord.GetSeverityText(),
+		Body:              otlpValue(logRecord.GetBody()),
+		Attributes:        otlpAttributes(logRecord.GetAttributes()),
+		TraceID:           hex.EncodeToString(logRecord.GetTraceId()),
+		SpanID:            hex.EncodeToString(logRecord.GetSpanId()),
+		Flags:             logRecord.GetFlags(),
+		DroppedAttributes: logRecord.GetDroppedAttributesCount(),
+		Resource:          resource,
+		Scope:             scope,
+	}
+}
+
+func otlpScope(scope *commonpb.InstrumentationScope) *otlpScopeJSON {
+	if scope == nil {
+		return nil
+	}
+	out := &otlpScopeJSON{
+		Name:       scope.GetName(),
+		Version:    scope.GetVersion(),
+		Attributes: otlpAttributes(scope.GetAttributes()),
+	}
+	if out.Name == "" && out.Version == "" && out.Attributes == nil {
+		return nil
+	}
+	return out
+}
+
+// otlpTime formats nanoseconds since the epoch; zero means unset
+func otlpTime(unixNano uint64) string {
+	if unixNano == 0 {
+		return ""
+	}
+	return time.Unix(0, int64(unixNano)).UTC().Format(time.RFC3339Nano)
+}
+
+func otlpAttributes(kvs []*commonpb.KeyValue) map[string]interface{} {
+	if len(kvs) == 0 {
+		return nil
+	}
+	out := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		out[kv.GetKey()] = otlpValue(kv.GetValue())
+	}
+	return out
+}
+
+// otlpValue converts an AnyValue into a JSON-friendly value
+func otlpValue(v *commonpb.AnyValue) interface{} {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return value.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return value.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		if math.IsNaN(value.DoubleValue) || math.IsInf(value.DoubleValue, 0) {
+			return nil
+		}
+		return value.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return value.BytesValue // Encoded as base64 by encoding/json
+	case *commonpb.AnyValue_ArrayValue:
+		values := value.ArrayValue.GetValues()
+		out := make([]interface{}, len(values))
+		for i, item := range values {
+			out[i] = otlpValue(item)
+		}
+		return out
+	case *commonpb.AnyValue_KvlistValue:
+		return otlpAttributes(value.KvlistValue.GetValues())
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package decoders
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// proxyV2Signature starts every PROXY protocol v2 header
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyV2HeaderLen is the fixed part of the header: signature, version/command,
+// family/transport and address length
+const proxyV2HeaderLen = 16
+
+// PROXY protocol v2 commands, address families and transports
+const (
+	proxyV2CommandLocal = 0x0
+	proxyV2CommandProxy = 0x1
+
+	proxyV2FamilyInet  = 0x1
+	proxyV2FamilyInet6 = 0x2
+
+	proxyV2TransportStream = 0x1
+)
+
+// ErrProxyHeader is returned for a missing or malformed PROXY protocol v2 header
+var ErrProxyHeader = errors.New("invalid proxy protocol v2 header")
+
+// ParseProxyV2 parses the PROXY protocol v2 header at the start of a datagram. It
+// returns the original source address, or nil for LOCAL commands and address
+// families without one, and the payload that follows the header.
+func ParseProxyV2(data []byte) (net.Addr, []byte, error) {
+	if len(data) < proxyV2HeaderLen {
+		return nil, nil, fmt.Errorf("%w: %d bytes", ErrProxyHeader, len(data))
+	}
+
+	length, err := proxyV2Length(data[:proxyV2HeaderLen])
+	if err != nil {
+		return nil, nil, err
+	}
+	end := proxyV2HeaderLen + length
+	if len(data) < end {
+		return nil, nil, fmt.Errorf("%w: truncated addresses", ErrProxyHeader)
+	}
+
+	source, err := proxyV2Source(data[:proxyV2HeaderLen], data[proxyV2HeaderLen:end])
+	if err != nil {
+		return nil, nil, err
+	}
+	return source, data[end:], nil
+}
+
+// ReadProxyV2 reads exactly one PROXY protocol v2 header from the start of a stream
+func ReadProxyV2(r io.Reader) (net.Addr, error) {
+	header := make([]byte, proxyV2HeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	length, err := proxyV2Length(header)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]byte, length)
+	if _, err := io.ReadFull(r, addresses); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	return proxyV2Source(header, addresses)
+}
+
+// proxyV2Length validates the fixed header and returns the length of the address block
+func proxyV2Length(header []byte) (int, error) {
+	if !bytes.Equal(header[:len(proxyV2Signature)], proxyV2Signature) {
+		return 0, fmt.Errorf("%w: missing signature", ErrProxyHeader)
+	}
+	if version := header[12] >> 4; version != 2 {
+		return 0, fmt.Errorf("%w: unsupported version %d", ErrProxyHeader, version)
+	}
+	return int(binary.BigEndian.Uint16(header[14:16])), nil
+}
+
+// proxyV2Source extracts the source address; TLVs after the addresses are ignored
+func proxyV2Source(header, addresses []byte) (net.Addr, error) {
+	switch command := header[12] & 0x0f; command {
+	case proxyV2CommandLocal:
+		return nil, nil // Health check from the balancer itself
+	case proxyV2CommandProxy:
+	default:
+		return nil, fmt.Errorf("%w: unknown command %d", ErrProxyHeader, command)
+	}
+
+	var ip net.IP
+	var port int
+	switch family := header[13] >> 4; family {
+	case proxyV2FamilyInet:
+		if len(addresses) < 12 {
+			return nil, fmt.Errorf("%w: short IPv4 addresses", ErrProxyHeader)
+		}
+		ip = net.IP(bytes.Clone(addresses[0:4]))
+		port = int(binary.BigEndian.Uint16(addresses[8:10]))
+	case proxyV2FamilyInet6:
+		if len(addresses) < 36 {
+			return nil, fmt.Errorf("%w: short IPv6 addresses", ErrProxyHeader)
+		}
+		ip = net.IP(bytes.Clone(addresses[0:16]))
+		port = int(binary.BigEndian.Uint16(addresses[32:34]))
+	default:
+		return nil, nil // UNSPEC and UNIX carry no usable network address
+	}
+
+	if header[13]&0x0f == proxyV2TransportStream {
+		return &net.TCPAddr{IP: ip, Port: port}, nil
+	}
+	return &net.UDPAddr{IP: ip, Port: port}, nil
+}
@@ -0,0 +1,398 @@
+package decoders
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// sFlow v5 sample and record formats (enterprise 0)
+const (
+	sflowVersion5 = 5
+
+	sflowFlowSample            = 1
+	sflowCounterSample         = 2
+	sflowExpandedFlowSample    = 3
+	sflowExpandedCounterSample = 4
+
+	sflowRawPacketHeader  = 1
+	sflowExtendedSwitch   = 1001
+	sflowGenericCounters  = 1
+	sflowEthernetCounters = 2
+
+	sflowHeaderEthernet = 1
+	sflowHeaderIPv4     = 11
+	sflowHeaderIPv6     = 12
+)
+
+// Ethernet and IP protocol numbers used by the header decoder
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88a8
+
+	ipProtoTCP = 6
+	ipProtoUDP = 17
+)
+
+var errSFlowTruncated = errors.New("sflow datagram truncated")
+
+// SFlowDecoder decodes sFlow v5 datagrams into one JSON record per flow or counter sample
+type SFlowDecoder struct{}
+
+// NewSFlowDecoder creates an sFlow v5 decoder
+func NewSFlowDecoder() *SFlowDecoder {
+	return &SFlowDecoder{}
+}
+
+// xdrReader reads big-endian XDR values and remembers the first error
+type xdrReader struct {
+	data []byte
+	err  error
+}
+
+func (r *xdrReader) u32() uint32 {
+	if r.err != nil || len(r.data) < 4 {
+		r.err = errSFlowTruncated
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *xdrReader) u64() uint64 {
+	if r.err != nil || len(r.data) < 8 {
+		r.err = errSFlowTruncated
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v
+}
+
+// bytes reads n bytes followed by XDR padding to a 4-byte boundary
+func (r *xdrReader) bytes(n int) []byte {
+	padded := (n + 3) &^ 3
+	if r.err != nil || n < 0 || len(r.data) < padded {
+		r.err = errSFlowTruncated
+		return nil
+	}
+	v := r.data[:n]
+	r.data = r.data[padded:]
+	return v
+}
+
+// address reads an XDR address (type + IPv4/IPv6 bytes)
+func (r *xdrReader) address() net.IP {
+	switch r.u32() {
+	case 1:
+		return net.IP(r.bytes(net.IPv4len))
+	case 2:
+		return net.IP(r.bytes(net.IPv6len))
+	default:
+		if r.err == nil {
+			r.err = errors.New("unknown sflow address type")
+		}
+		return nil
+	}
+}
+
+// sflowHeader carries datagram-level values copied into every sample record
+type sflowHeader struct {
+	agent      string
+	subAgentID uint32
+	sequence   uint32
+	uptimeMs   uint32
+}
+
+// Decode decodes an sFlow v5 datagram. Records decoded before an error are still returned.
+func (d *SFlowDecoder) Decode(data []byte, from net.Addr, now time.Time) ([][]byte, error) {
+	r := &xdrReader{data: data}
+
+	if version := r.u32(); r.err == nil && version != sflowVersion5 {
+		return nil, fmt.Errorf("unsupported sflow version %d", version)
+	}
+
+	header := sflowHeader{}
+	agent := r.address()
+	header.subAgentID = r.u32()
+	header.sequence = r.u32()
+	header.uptimeMs = r.u32()
+	numSamples := r.u32()
+	if r.err != nil {
+		return nil, r.err
+	}
+	header.agent = agent.String()
+
+	var records [][]byte
+	for i := uint32(0); i < numSamples; i++ {
+		format := r.u32()
+		length := r.u32()
+		body := r.bytes(int(length))
+		if r.err != nil {
+			return records, r.err
+		}
+
+		enterprise, sampleType := format>>12, format&0xfff
+		if enterprise != 0 {
+			continue
+		}
+
+		var record map[string]interface{}
+		var err error
+		switch sampleType {
+		case sflowFlowSample, sflowExpandedFlowSample:
+			record, err = decodeSFlowFlowSample(body, sampleType == sflowExpandedFlowSample)
+		case sflowCounterSample, sflowExpandedCounterSample:
+			record, err = decodeSFlowCounterSample(body, sampleType == sflowExpandedCounterSample)
+		default:
+			continue
+		}
+		if err != nil {
+			return records, err
+		}
+
+		record["sflow_version"] = sflowVersion5
+		record["agent"] = header.agent
+		record["sub_agent_id"] = header.subAgentID
+		record["datagram_sequence"] = header.sequence
+		record["agent_uptime_ms"] = header.uptimeMs
+		record["exporter"] = sourceHost(from)
+		record["received_at"] = now.UTC().Format(time.RFC3339Nano)
+
+		jsonBytes, err := json.Marshal(record)
+		if err != nil {
+			return records, err
+		}
+		records = append(records, jsonBytes)
+	}
+
+	return records, nil
+}
+
+// decodeSFlowFlowSample decodes a (expanded) flow sample and its flow records
+func decodeSFlowFlowSample(data []byte, expanded bool) (map[string]interface{}, error) {
+	r := &xdrReader{data: data}
+	record := map[string]interface{}{"sample_type": "flow"}
+
+	record["sample_sequence"] = r.u32()
+	if expanded {
+		record["source_id_type"] = r.u32()
+		record["source_id_index"] = r.u32()
+	} else {
+		sourceID := r.u32()
+		record["source_id_type"] = sourceID >> 24
+		record["source_id_index"] = sourceID & 0xffffff
+	}
+	record["sampling_rate"] = r.u32()
+	record["sample_pool"] = r.u32()
+	record["drops"] = r.u32()
+	if expanded {
+		r.u32() // input format
+		record["input_if"] = r.u32()
+		r.u32() // output format
+		record["output_if"] = r.u32()
+	} else {
+		record["input_if"] = r.u32() & 0x3fffffff
+		record["output_if"] = r.u32() & 0x3fffffff
+	}
+
+	numRecords := r.u32()
+	for i := uint32(0); i < numRecords && r.err == nil; i++ {
+		format := r.u32()
+		body := r.bytes(int(r.u32()))
+		if r.err != nil || format>>12 != 0 {
+			continue
+		}
+
+		switch format & 0xfff {
+		case sflowRawPacketHeader:
+			decodeSFlowRawHeader(body, record)
+		case sflowExtendedSwitch:
+			sw := &xdrReader{data: body}
+			record["src_vlan"] = sw.u32()
+			record["src_priority"] = sw.u32()
+			record["dst_vlan"] = sw.u32()
+			record["dst_priority"] = sw.u32()
+		}
+	}
+
+	return record, r.err
+}
+
+// decodeSFlowRawHeader decodes a sampled packet header record into the flow record
+func decodeSFlowRawHeader(data []byte, record map[string]interface{}) {
+	r := &xdrReader{data: data}
+	protocol := r.u32()
+	record["frame_length"] = r.u32()
+	record["stripped"] = r.u32()
+	header := r.bytes(int(r.u32()))
+	if r.err != nil {
+		return
+	}
+	record["header_protocol"] = protocol
+
+	switch protocol {
+	case sflowHeaderEthernet:
+		decodeEthernet(header, record)
+	case sflowHeaderIPv4:
+		decodeIPv4(header, record)
+	case sflowHeaderIPv6:
+		decodeIPv6(header, record)
+	}
+}
+
+// decodeEthernet decodes an Ethernet II header (with optional VLAN tags)
+func decodeEthernet(data []byte, record map[string]interface{}) {
+	if len(data) < 14 {
+		return
+	}
+	record["dst_mac"] = net.HardwareAddr(data[0:6]).String()
+	record["src_mac"] = net.HardwareAddr(data[6:12]).String()
+
+	etherType := binary.BigEndian.Uint16(data[12:])
+	data = data[14:]
+	for (etherType == etherTypeVLAN || etherType == etherTypeQinQ) && len(data) >= 4 {
+		record["vlan"] = binary.BigEndian.Uint16(data) & 0x0fff
+		etherType = binary.BigEndian.Uint16(data[2:])
+		data = data[4:]
+	}
+	record["ether_type"] = etherType
+
+	switch etherType {
+	case etherTypeIPv4:
+		decodeIPv4(data, record)
+	case etherTypeIPv6:
+		decodeIPv6(data, record)
+	}
+}
+
+// decodeIPv4 decodes an IPv4 header and the TCP/UDP ports that follow it
+func decodeIPv4(data []byte, record map[string]interface{}) {
+	if len(data) < 20 || data[0]>>4 != 4 {
+		return
+	}
+	headerLen := int(data[0]&0x0f) * 4
+	record["ip_version"] = 4
+	record["tos"] = data[1]
+	record["ip_length"] = binary.BigEndian.Uint16(data[2:])
+	record["ttl"] = data[8]
+	record["protocol"] = data[9]
+	record["src_addr"] = net.IP(data[12:16]).String()
+	record["dst_addr"] = net.IP(data[16:20]).String()
+
+	// Only the first fragment carries the transport header
+	if binary.BigEndian.Uint16(data[6:])&0x1fff != 0 || headerLen < 20 || len(data) < headerLen {
+		return
+	}
+	decodeTransport(data[9], data[headerLen:], record)
+}
+
+// decodeIPv6 decodes an IPv6 header and the TCP/UDP ports that follow it
+func decodeIPv6(data []byte, record map[string]interface{}) {
+	if len(data) < 40 || data[0]>>4 != 6 {
+		return
+	}
+	record["ip_version"] = 6
+	record["tos"] = (binary.BigEndian.Uint16(data) >> 4) & 0xff
+	record["flow_label"] = binary.BigEndian.Uint32(data) & 0x000fffff
+	record["ip_length"] = binary.BigEndian.Uint16(data[4:])
+	record["protocol"] = data[6]
+	record["ttl"] = data[7]
+	record["src_addr"] = net.IP(data[8:24]).String()
+	record["dst_addr"] = net.IP(data[24:40]).String()
+
+	decodeTransport(data[6], data[40:], record)
+}
+
+// decodeTransport decodes TCP/UDP ports (and TCP flags)
+func decodeTransport(protocol byte, data []byte, record map[string]interface{}) {
+	switch protocol {
+	case ipProtoTCP:
+		if len(data) < 14 {
+			return
+		}
+		record["src_port"] = binary.BigEndian.Uint16(data)
+		record["dst_port"] = binary.BigEndian.Uint16(data[2:])
+		record["tcp_flags"] = data[13]
+	case ipProtoUDP:
+		if len(data) < 4 {
+			return
+		}
+		record["src_port"] = binary.BigEndian.Uint16(data)
+		record["dst_port"] = binary.BigEndian.Uint16(data[2:])
+	}
+}
+
+// decodeSFlowCounterSample decodes a (expanded) counter sample and its counter records
+func decodeSFlowCounterSample(data []byte, expanded bool) (map[string]interface{}, error) {
+	r := &xdrReader{data: data}
+	record := map[string]interface{}{"sample_type": "counter"}
+
+	record["sample_sequence"] = r.u32()
+	if expanded {
+		record["source_id_type"] = r.u32()
+		record["source_id_index"] = r.u32()
+	} else {
+		sourceID := r.u32()
+		record["source_id_type"] = sourceID >> 24
+		record["source_id_index"] = sourceID & 0xffffff
+	}
+
+	numRecords := r.u32()
+	for i := uint32(0); i < numRecords && r.err == nil; i++ {
+		format := r.u32()
+		body := r.bytes(int(r.u32()))
+		if r.err != nil || format>>12 != 0 {
+			continue
+		}
+
+		c := &xdrReader{data: body}
+		switch format & 0xfff {
+		case sflowGenericCounters:
+			record["if_index"] = c.u32()
+			record["if_type"] = c.u32()
+			record["if_speed"] = c.u64()
+			record["if_direction"] = c.u32()
+			record["if_status"] = c.u32()
+			record["if_in_octets"] = c.u64()
+			record["if_in_ucast_pkts"] = c.u32()
+			record["if_in_multicast_pkts"] = c.u32()
+			record["if_in_broadcast_pkts"] = c.u32()
+			record["if_in_discards"] = c.u32()
+			record["if_in_errors"] = c.u32()
+			record["if_in_unknown_protos"] = c.u32()
+			record["if_out_octets"] = c.u64()
+			record["if_out_ucast_pkts"] = c.u32()
+			record["if_out_multicast_pkts"] = c.u32()
+			record["if_out_broadcast_pkts"] = c.u32()
+			record["if_out_discards"] = c.u32()
+			record["if_out_errors"] = c.u32()
+			record["if_promiscuous_mode"] = c.u32()
+		case sflowEthernetCounters:
+			record["dot3_alignment_errors"] = c.u32()
+			record["dot3_fcs_errors"] = c.u32()
+			record["dot3_single_collision_frames"] = c.u32()
+			record["dot3_multiple_collision_frames"] = c.u32()
+			record["dot3_sqe_test_errors"] = c.u32()
+			record["dot3_deferred_transmissions"] = c.u32()
+			record["dot3_late_collisions"] = c.u32()
+			record["dot3_excessive_collisions"] = c.u32()
+			record["dot3_internal_mac_transmit_errors"] = c.u32()
+			record["dot3_carrier_sense_errors"] = c.u32()
+			record["dot3_frame_too_longs"] = c.u32()
+			record["dot3_internal_mac_receive_errors"] = c.u32()
+			record["dot3_symbol_errors"] = c.u32()
+		}
+		if c.err != nil {
+			return record, c.err
+		}
+	}
+
+	return record, r.err
+}
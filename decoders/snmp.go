@@ -0,0 +1,540 @@
+package decoders
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// BER universal and SNMP application tags
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berNull        = 0x05
+	berOID         = 0x06
+	berSequence    = 0x30
+
+	snmpIPAddress  = 0x40
+	snmpCounter32  = 0x41
+	snmpGauge32    = 0x42
+	snmpTimeTicks  = 0x43
+	snmpOpaque     = 0x44
+	snmpCounter64  = 0x46
+	snmpUInteger32 = 0x47
+
+	snmpNoSuchObject   = 0x80
+	snmpNoSuchInstance = 0x81
+	snmpEndOfMibView   = 0x82
+)
+
+// SNMP PDU types accepted by the trap decoder
+const (
+	snmpPDUTrapV1  = 0xa4
+	snmpPDUInform  = 0xa6
+	snmpPDUTrapV2  = 0xa7
+	snmpVersion1   = 0
+	snmpVersion2c  = 1
+	snmpVersion3   = 3
+	snmpModelUSM   = 3
+	snmpFlagAuth   = 0x01
+	snmpFlagPriv   = 0x02
+	snmpMaxOIDArcs = 128
+)
+
+// Well-known OIDs carried in SNMPv2 trap varbinds
+const (
+	oidSysUpTime    = "1.3.6.1.2.1.1.3.0"
+	oidSNMPTrapOID  = "1.3.6.1.6.3.1.1.4.1.0"
+	oidGenericTraps = "1.3.6.1.6.3.1.1.5"
+)
+
+var (
+	// ErrSNMPMalformed is returned for datagrams that are not valid BER-encoded SNMP traps
+	ErrSNMPMalformed = errors.New("malformed snmp message")
+	// ErrSNMPAuth is returned for community or USM authentication/decryption failures
+	ErrSNMPAuth = errors.New("snmp authentication failed")
+	// ErrSNMPInformUnacknowledged is returned alongside the record of an InformRequest,
+	// which the decoder cannot answer with a Response PDU
+	ErrSNMPInformUnacknowledged = errors.New("snmp inform recorded but not acknowledged")
+)
+
+// SNMPTrapDecoder decodes SNMP v1, v2c and v3 (USM) traps into one JSON record per trap
+type SNMPTrapDecoder struct {
+	communities map[string]bool // Accepted v1/v2c communities; empty accepts any
+	users       map[string]SNMPUser
+	mibNames    *MIBNames
+
+	masters map[string]usmKeys // Passphrase keys per user, derived once at startup
+
+	mu       sync.Mutex
+	keys     map[usmKeyID]*list.Element // Localized keys per user and engine ID
+	keyOrder *list.List                 // Most recently used first
+}
+
+// NewSNMPTrapDecoder creates an SNMP trap decoder. mibNames may be nil.
+func NewSNMPTrapDecoder(communities []string, users []SNMPUser, mibNames *MIBNames) *SNMPTrapDecoder {
+	d := &SNMPTrapDecoder{
+		communities: make(map[string]bool, len(communities)),
+		users:       make(map[string]SNMPUser, len(users)),
+		mibNames:    mibNames,
+		masters:     make(map[string]usmKeys, len(users)),
+		keys:        make(map[usmKeyID]*list.Element),
+		keyOrder:    list.New(),
+	}
+	for _, community := range communities {
+		d.communities[community] = true
+	}
+	for _, user := range users {
+		d.users[user.Username] = user
+		if newHash, _, ok := usmAuthHash(user.AuthProtocol); ok {
+			d.masters[user.Username] = passphraseKeys(newHash, user)
+		}
+	}
+	return d
+}
+
+// Decode decodes a single trap datagram
+func (d *SNMPTrapDecoder) Decode(data []byte, from net.Addr, now time.Time) ([][]byte, error) {
+	root := berReader{data: data}
+	msg, err := root.expect(berSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	versionTLV, err := msg.expect(berInteger)
+	if err != nil {
+		return nil, err
+	}
+	version := versionTLV.int()
+
+	record := map[string]interface{}{
+		"source":      sourceHost(from),
+		"received_at": now.UTC().Format(time.RFC3339Nano),
+	}
+
+	var pdu berReader
+	switch version {
+	case snmpVersion1, snmpVersion2c:
+		community, err := msg.expect(berOctetString)
+		if err != nil {
+			return nil, err
+		}
+		if len(d.communities) > 0 && !d.communities[string(community.data)] {
+			return nil, fmt.Errorf("%w: unknown community", ErrSNMPAuth)
+		}
+		record["snmp_version"] = "1"
+		if version == snmpVersion2c {
+			record["snmp_version"] = "2c"
+		}
+		record["community"] = string(community.data)
+		pdu, err = msg.next()
+		if err != nil {
+			return nil, err
+		}
+
+	case snmpVersion3:
+		pdu, err = d.decodeV3(data, msg, record)
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrSNMPMalformed, version)
+	}
+
+	if err := d.decodePDU(pdu, record); err != nil {
+		return nil, err
+	}
+
+	jsonBytes, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	if pdu.tag == snmpPDUInform {
+		// The sender retransmits until it times out, so the inform may be recorded again
+		return [][]byte{jsonBytes}, ErrSNMPInformUnacknowledged
+	}
+	return [][]byte{jsonBytes}, nil
+}
+
+// decodePDU decodes a v1 Trap-PDU or a v2 SNMPv2-Trap/InformRequest PDU
+func (d *SNMPTrapDecoder) decodePDU(pdu berReader, record map[string]interface{}) error {
+	switch pdu.tag {
+	case snmpPDUTrapV1:
+		enterprise, err := pdu.expect(berOID)
+		if err != nil {
+			return err
+		}
+		agentAddr, err := pdu.expect(snmpIPAddress)
+		if err != nil {
+			return err
+		}
+		generic, err := pdu.expect(berInteger)
+		if err != nil {
+			return err
+		}
+		specific, err := pdu.expect(berInteger)
+		if err != nil {
+			return err
+		}
+		uptime, err := pdu.expect(snmpTimeTicks)
+		if err != nil {
+			return err
+		}
+
+		enterpriseOID, err := enterprise.oid()
+		if err != nil {
+			return err
+		}
+		record["pdu_type"] = "trap"
+		record["enterprise"] = enterpriseOID
+		record["agent_address"] = net.IP(agentAddr.data).String()
+		record["generic_trap"] = generic.int()
+		record["specific_trap"] = specific.int()
+		record["uptime_ticks"] = uptime.uint()
+
+		// RFC 3584 section 3.1 mapping of v1 traps onto SNMPv2 trap OIDs
+		if generic.int() == 6 {
+			record["trap_oid"] = enterpriseOID + ".0." + strconv.FormatInt(specific.int(), 10)
+		} else {
+			record["trap_oid"] = oidGenericTraps + "." + strconv.FormatInt(generic.int()+1, 10)
+		}
+
+	case snmpPDUTrapV2, snmpPDUInform:
+		requestID, err := pdu.expect(berInteger)
+		if err != nil {
+			return err
+		}
+		if _, err := pdu.expect(berInteger); err != nil { // error-status
+			return err
+		}
+		if _, err := pdu.expect(berInteger); err != nil { // error-index
+			return err
+		}
+		record["pdu_type"] = "trap"
+		if pdu.tag == snmpPDUInform {
+			record["pdu_type"] = "inform"
+		}
+		record["request_id"] = requestID.int()
+
+	default:
+		return fmt.Errorf("%w: pdu type 0x%02x is not a trap", ErrSNMPMalformed, pdu.tag)
+	}
+
+	varbinds, err := d.decodeVarbinds(pdu)
+	if err != nil {
+		return err
+	}
+	for _, varbind := range varbinds {
+		switch varbind["oid"] {
+		case oidSysUpTime:
+			record["uptime_ticks"] = varbind["value"]
+		case oidSNMPTrapOID:
+			record["trap_oid"] = varbind["value"]
+		}
+	}
+	if trapOID, ok := record["trap_oid"].(string); ok {
+		if name := d.mibNames.Lookup(trapOID); name != "" {
+			record["trap_name"] = name
+		}
+	}
+	record["varbinds"] = varbinds
+	return nil
+}
+
+// decodeVarbinds decodes the VarBindList that ends a PDU
+func (d *SNMPTrapDecoder) decodeVarbinds(pdu berReader) ([]map[string]interface{}, error) {
+	list, err := pdu.expect(berSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	varbinds := []map[string]interface{}{}
+	for !list.empty() {
+		varbind, err := list.expect(berSequence)
+		if err != nil {
+			return nil, err
+		}
+		name, err := varbind.expect(berOID)
+		if err != nil {
+			return nil, err
+		}
+		oid, err := name.oid()
+		if err != nil {
+			return nil, err
+		}
+		value, err := varbind.next()
+		if err != nil {
+			return nil, err
+		}
+
+		typeName, decoded, err := snmpValue(value)
+		if err != nil {
+			return nil, err
+		}
+		entry := map[string]interface{}{"oid": oid, "type": typeName, "value": decoded}
+		if mibName := d.mibNames.Lookup(oid); mibName != "" {
+			entry["name"] = mibName
+		}
+		if typeName == "oid" {
+			if valueName := d.mibNames.Lookup(decoded.(string)); valueName != "" {
+				entry["value_name"] = valueName
+			}
+		}
+		varbinds = append(varbinds, entry)
+	}
+	return varbinds, nil
+}
+
+// snmpValue converts a varbind value into its type name and a JSON-friendly value
+func snmpValue(v berReader) (string, interface{}, error) {
+	switch v.tag {
+	case berInteger:
+		return "integer", v.int(), nil
+	case berOctetString:
+		if utf8.Valid(v.data) && isPrintable(v.data) {
+			return "octet_string", string(v.data), nil
+		}
+		return "octet_string", hex.EncodeToString(v.data), nil
+	case berNull:
+		return "null", nil, nil
+	case berOID:
+		oid, err := v.oid()
+		return "oid", oid, err
+	case snmpIPAddress:
+		return "ip_address", net.IP(v.data).String(), nil
+	case snmpCounter32:
+		return "counter32", v.uint(), nil
+	case snmpGauge32:
+		return "gauge32", v.uint(), nil
+	case snmpTimeTicks:
+		return "timeticks", v.uint(), nil
+	case snmpCounter64:
+		return "counter64", v.uint(), nil
+	case snmpUInteger32:
+		return "uinteger32", v.uint(), nil
+	case snmpOpaque:
+		return "opaque", hex.EncodeToString(v.data), nil
+	case snmpNoSuchObject:
+		return "no_such_object", nil, nil
+	case snmpNoSuchInstance:
+		return "no_such_instance", nil, nil
+	case snmpEndOfMibView:
+		return "end_of_mib_view", nil, nil
+	}
+	return "", nil, fmt.Errorf("%w: unknown varbind type 0x%02x", ErrSNMPMalformed, v.tag)
+}
+
+func isPrintable(data []byte) bool {
+	for _, r := range string(data) {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// berReader walks a sequence of BER TLVs. offset is the position of data within
+// the original datagram, needed to locate the USM authentication parameters.
+type berReader struct {
+	tag    byte
+	data   []byte
+	offset int
+}
+
+// next reads the next TLV from the reader
+func (r *berReader) next() (berReader, error) {
+	if len(r.data) < 2 {
+		return berReader{}, fmt.Errorf("%w: truncated", ErrSNMPMalformed)
+	}
+	tag := r.data[0]
+	length := int(r.data[1])
+	header := 2
+
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 4 || len(r.data) < 2+n {
+			return berReader{}, fmt.Errorf("%w: invalid length", ErrSNMPMalformed)
+		}
+		length = 0
+		for _, b := range r.data[2 : 2+n] {
+			length = length<<8 | int(b)
+		}
+		header += n
+	}
+	if length < 0 || len(r.data)-header < length {
+		return berReader{}, fmt.Errorf("%w: truncated", ErrSNMPMalformed)
+	}
+
+	tlv := berReader{tag: tag, data: r.data[header : header+length], offset: r.offset + header}
+	r.data = r.data[header+length:]
+	r.offset += header + length
+	return tlv, nil
+}
+
+// expect reads the next TLV and checks its tag
+func (r *berReader) expect(tag byte) (berReader, error) {
+	tlv, err := r.next()
+	if err != nil {
+		return tlv, err
+	}
+	if tlv.tag != tag {
+		return tlv, fmt.Errorf("%w: expected tag 0x%02x, got 0x%02x", ErrSNMPMalformed, tag, tlv.tag)
+	}
+	return tlv, nil
+}
+
+func (r berReader) empty() bool {
+	return len(r.data) == 0
+}
+
+// int decodes a two's complement INTEGER
+func (r berReader) int() int64 {
+	if len(r.data) == 0 {
+		return 0
+	}
+	v := int64(int8(r.data[0]))
+	for _, b := range r.data[1:min(len(r.data), 8)] {
+		v = v<<8 | int64(b)
+	}
+	return v
+}
+
+// uint decodes an unsigned application type (Counter32, Gauge32, TimeTicks, Counter64)
+func (r berReader) uint() uint64 {
+	var v uint64
+	for _, b := range r.data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+// oid decodes an OBJECT IDENTIFIER into dotted notation
+func (r berReader) oid() (string, error) {
+	if len(r.data) == 0 {
+		return "", fmt.Errorf("%w: empty oid", ErrSNMPMalformed)
+	}
+
+	var sb strings.Builder
+	var value uint64
+	arcs := 0
+	for i, b := range r.data {
+		if value > 1<<56 {
+			return "", fmt.Errorf("%w: oid arc overflow", ErrSNMPMalformed)
+		}
+		value = value<<7 | uint64(b&0x7f)
+		if b&0x80 != 0 {
+			if i == len(r.data)-1 {
+				return "", fmt.Errorf("%w: truncated oid", ErrSNMPMalformed)
+			}
+			continue
+		}
+
+		if arcs == 0 {
+			// The first subidentifier encodes the first two arcs
+			first := min(value/40, 2)
+			sb.WriteString(strconv.FormatUint(first, 10))
+			sb.WriteByte('.')
+			sb.WriteString(strconv.FormatUint(value-first*40, 10))
+			arcs = 2
+		} else {
+			sb.WriteByte('.')
+			sb.WriteString(strconv.FormatUint(value, 10))
+			arcs++
+		}
+		if arcs > snmpMaxOIDArcs {
+			return "", fmt.Errorf("%w: oid too long", ErrSNMPMalformed)
+		}
+		value = 0
+	}
+	return sb.String(), nil
+}
+
+// MIBNames maps numeric OIDs to symbolic names for trap records
+type MIBNames struct {
+	names map[string]string
+}
+
+// LoadMIBNames reads a MIB name mapping file. Each non-comment line holds a name and
+// a numeric OID separated by whitespace, as printed by `snmptranslate -Tz`; quotes
+// are ignored and the order of the two columns does not matter.
+func LoadMIBNames(path string) (*MIBNames, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	m := &MIBNames{names: make(map[string]string)}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(strings.ReplaceAll(line, `"`, ""))
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected name and oid", path, lineNum)
+		}
+		name, oid := fields[0], strings.TrimPrefix(fields[1], ".")
+		if isNumericOID(name) {
+			name, oid = fields[1], strings.TrimPrefix(fields[0], ".")
+		}
+		if !isNumericOID(oid) {
+			return nil, fmt.Errorf("%s:%d: invalid oid %q", path, lineNum, oid)
+		}
+		m.names[oid] = name
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Lookup returns the name of the longest known prefix of oid, followed by the
+// remaining instance arcs (e.g. "ifIndex.3"); it returns "" when nothing matches
+func (m *MIBNames) Lookup(oid string) string {
+	if m == nil {
+		return ""
+	}
+	for prefix := oid; prefix != ""; {
+		if name, ok := m.names[prefix]; ok {
+			return name + oid[len(prefix):]
+		}
+		i := strings.LastIndexByte(prefix, '.')
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return ""
+}
+
+func isNumericOID(s string) bool {
+	s = strings.TrimPrefix(s, ".")
+	if s == "" {
+		return false
+	}
+	for _, part := range strings.Split(s, ".") {
+		if part == "" {
+			return false
+		}
+		if _, err := strconv.ParseUint(part, 10, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
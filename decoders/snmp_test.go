@@ -0,0 +1,141 @@
+package decoders
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// tlv encodes a BER TLV with a short or long form length
+func tlv(tag byte, parts ...[]byte) []byte {
+	var body []byte
+	for _, part := range parts {
+		body = append(body, part...)
+	}
+	out := []byte{tag}
+	if len(body) < 0x80 {
+		out = append(out, byte(len(body)))
+	} else {
+		out = append(out, 0x82, byte(len(body)>>8), byte(len(body)))
+	}
+	return append(out, body...)
+}
+
+func berInt(v int64) []byte {
+	var b []byte
+	for {
+		b = append([]byte{byte(v)}, b...)
+		if v >= -128 && v <= 127 {
+			break
+		}
+		v >>= 8
+	}
+	return tlv(berInteger, b)
+}
+
+// snmpPDU builds a trap or inform PDU carrying sysUpTime and snmpTrapOID.0 (coldStart)
+func snmpPDU(tag byte) []byte {
+	sysUpTime := []byte{0x2b, 6, 1, 2, 1, 1, 3, 0}
+	trapOID := []byte{0x2b, 6, 1, 6, 3, 1, 1, 4, 1, 0}
+	coldStart := []byte{0x2b, 6, 1, 6, 3, 1, 1, 5, 1}
+	return tlv(tag, berInt(42), berInt(0), berInt(0), tlv(berSequence,
+		tlv(berSequence, tlv(berOID, sysUpTime), tlv(snmpTimeTicks, []byte{0x01, 0x00})),
+		tlv(berSequence, tlv(berOID, trapOID), tlv(berOID, coldStart)),
+	))
+}
+
+// snmpV3Message builds an authNoPriv SNMPv3 trap signed with the user's localized key
+func snmpV3Message(t *testing.T, user SNMPUser, engineID []byte, boots, engineTime int64) []byte {
+	t.Helper()
+	newHash, digestLen, _ := usmAuthHash(user.AuthProtocol)
+	key := localizeKey(newHash, passphraseKey(newHash, user.AuthPassphrase), engineID)
+
+	build := func(digest []byte) []byte {
+		secParams := tlv(berSequence,
+			tlv(berOctetString, engineID), berInt(boots), berInt(engineTime),
+			tlv(berOctetString, []byte(user.Username)), tlv(berOctetString, digest), tlv(berOctetString))
+		return tlv(berSequence,
+			berInt(snmpVersion3),
+			tlv(berSequence, berInt(7), berInt(65507), tlv(berOctetString, []byte{snmpFlagAuth}), berInt(snmpModelUSM)),
+			tlv(berOctetString, secParams),
+			tlv(berSequence, tlv(berOctetString, engineID), tlv(berOctetString), snmpPDU(snmpPDUTrapV2)))
+	}
+
+	mac := hmac.New(newHash, key)
+	mac.Write(build(make([]byte, digestLen)))
+	return build(mac.Sum(nil)[:digestLen])
+}
+
+func TestSNMPInformUnacknowledged(t *testing.T) {
+	d := NewSNMPTrapDecoder(nil, nil, nil)
+	from := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 162}
+
+	tests := []struct {
+		name    string
+		pdu     byte
+		want    string
+		wantErr error
+	}{
+		{name: "trap", pdu: snmpPDUTrapV2, want: "trap"},
+		{name: "inform", pdu: snmpPDUInform, want: "inform", wantErr: ErrSNMPInformUnacknowledged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tlv(berSequence, berInt(snmpVersion2c), tlv(berOctetString, []byte("public")), snmpPDU(tt.pdu))
+			records, err := d.Decode(data, from, time.Now())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(records) != 1 {
+				t.Fatalf("Decode() returned %d records, want 1", len(records))
+			}
+
+			var record map[string]interface{}
+			if err := json.Unmarshal(records[0], &record); err != nil {
+				t.Fatal(err)
+			}
+			if record["pdu_type"] != tt.want || record["trap_oid"] != "1.3.6.1.6.3.1.1.5.1" {
+				t.Errorf("record = %v, want pdu_type %s and the coldStart trap OID", record, tt.want)
+			}
+		})
+	}
+}
+
+func TestSNMPv3Timeliness(t *testing.T) {
+	user := SNMPUser{Username: "trapuser", AuthProtocol: SNMPAuthSHA, AuthPassphrase: "changeme-auth"}
+	engineID := []byte{0x80, 0x00, 0x1f, 0x88, 0x04, 't', 'e', 's', 't'}
+
+	// Messages are decoded in order against one decoder
+	steps := []struct {
+		name       string
+		boots      int64
+		engineTime int64
+		wantErr    error
+	}{
+		{name: "first message", boots: 3, engineTime: 1000},
+		{name: "replay", boots: 3, engineTime: 1000},
+		{name: "older within the window", boots: 3, engineTime: 850},
+		{name: "older than the window", boots: 3, engineTime: 849, wantErr: ErrSNMPAuth},
+		{name: "newer", boots: 3, engineTime: 2000},
+		{name: "earlier boot", boots: 2, engineTime: 5000, wantErr: ErrSNMPAuth},
+		{name: "reboot", boots: 4, engineTime: 10},
+		{name: "previous boot after reboot", boots: 3, engineTime: 2000, wantErr: ErrSNMPAuth},
+		{name: "boots exhausted", boots: usmMaxEngineBoots, engineTime: 10, wantErr: ErrSNMPAuth},
+	}
+
+	d := NewSNMPTrapDecoder(nil, []SNMPUser{user}, nil)
+	from := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 162}
+	for _, step := range steps {
+		records, err := d.Decode(snmpV3Message(t, user, engineID, step.boots, step.engineTime), from, time.Now())
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: Decode() error = %v, want %v", step.name, err, step.wantErr)
+		}
+		if step.wantErr == nil && len(records) != 1 {
+			t.Fatalf("%s: Decode() returned %d records, want 1", step.name, len(records))
+		}
+	}
+}
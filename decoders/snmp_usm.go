@@ -0,0 +1,412 @@
+package decoders
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// SNMPv3 USM authentication and privacy protocols
+const (
+	SNMPAuthMD5    = "md5"
+	SNMPAuthSHA    = "sha"
+	SNMPAuthSHA224 = "sha224"
+	SNMPAuthSHA256 = "sha256"
+	SNMPAuthSHA384 = "sha384"
+	SNMPAuthSHA512 = "sha512"
+
+	SNMPPrivDES = "des"
+	SNMPPrivAES = "aes" // AES-128 CFB (RFC 3826)
+)
+
+// usmMaxCachedKeys bounds the localized key cache (one entry per user and engine ID);
+// the least recently used entry is evicted first
+const usmMaxCachedKeys = 1024
+
+// RFC 3414 timeliness: messages more than usmTimeWindow seconds older than the latest
+// seen from an engine are rejected, as are all messages once boots reaches its maximum
+const (
+	usmTimeWindow     = 150
+	usmMaxEngineBoots = 2147483647
+)
+
+// SNMPUser is an SNMPv3 USM user allowed to send traps
+type SNMPUser struct {
+	Username       string
+	AuthProtocol   string // Empty for noAuthNoPriv
+	AuthPassphrase string
+	PrivProtocol   string // Empty for no privacy
+	PrivPassphrase string
+}
+
+// Validate checks that the user's protocols are supported and passphrases are set
+func (u SNMPUser) Validate() error {
+	if u.Username == "" {
+		return fmt.Errorf("snmp user requires a username")
+	}
+	if u.AuthProtocol == "" {
+		if u.PrivProtocol != "" {
+			return fmt.Errorf("snmp user %s: privacy requires authentication", u.Username)
+		}
+		return nil
+	}
+	if _, _, ok := usmAuthHash(u.AuthProtocol); !ok {
+		return fmt.Errorf("snmp user %s: unsupported auth protocol %q", u.Username, u.AuthProtocol)
+	}
+	if len(u.AuthPassphrase) < 8 {
+		return fmt.Errorf("snmp user %s: auth passphrase must be at least 8 characters", u.Username)
+	}
+	switch strings.ToLower(u.PrivProtocol) {
+	case "":
+		return nil
+	case SNMPPrivDES, SNMPPrivAES:
+	default:
+		return fmt.Errorf("snmp user %s: unsupported priv protocol %q", u.Username, u.PrivProtocol)
+	}
+	if len(u.PrivPassphrase) < 8 {
+		return fmt.Errorf("snmp user %s: priv passphrase must be at least 8 characters", u.Username)
+	}
+	return nil
+}
+
+type usmKeyID struct {
+	username string
+	engineID string
+}
+
+type usmKeys struct {
+	auth []byte
+	priv []byte
+}
+
+// usmKeyEntry is an element of the localized key cache. It also holds the latest
+// msgAuthoritativeEngineBoots/Time authenticated for the user and engine.
+type usmKeyEntry struct {
+	id         usmKeyID
+	keys       *usmKeys
+	seen       bool
+	boots      int64
+	engineTime int64
+}
+
+// decodeV3 checks the USM security parameters of an SNMPv3 message, decrypting the
+// scoped PDU if needed, and returns the PDU
+func (d *SNMPTrapDecoder) decodeV3(data []byte, msg berReader, record map[string]interface{}) (berReader, error) {
+	header, err := msg.expect(berSequence)
+	if err != nil {
+		return berReader{}, err
+	}
+	msgID, err := header.expect(berInteger)
+	if err != nil {
+		return berReader{}, err
+	}
+	if _, err := header.expect(berInteger); err != nil { // msgMaxSize
+		return berReader{}, err
+	}
+	flagsTLV, err := header.expect(berOctetString)
+	if err != nil {
+		return berReader{}, err
+	}
+	model, err := header.expect(berInteger)
+	if err != nil {
+		return berReader{}, err
+	}
+	if len(flagsTLV.data) != 1 || model.int() != snmpModelUSM {
+		return berReader{}, fmt.Errorf("%w: unsupported v3 header", ErrSNMPMalformed)
+	}
+	flags := flagsTLV.data[0]
+
+	secParamsTLV, err := msg.expect(berOctetString)
+	if err != nil {
+		return berReader{}, err
+	}
+	secParams, err := secParamsTLV.expect(berSequence)
+	if err != nil {
+		return berReader{}, err
+	}
+	var fields [6]berReader
+	for i, tag := range []byte{berOctetString, berInteger, berInteger, berOctetString, berOctetString, berOctetString} {
+		if fields[i], err = secParams.expect(tag); err != nil {
+			return berReader{}, err
+		}
+	}
+	engineID, boots, engineTime := fields[0].data, fields[1].int(), fields[2].int()
+	username, authParams, privParams := string(fields[3].data), fields[4], fields[5].data
+
+	user, ok := d.users[username]
+	if !ok {
+		return berReader{}, fmt.Errorf("%w: unknown user %q", ErrSNMPAuth, username)
+	}
+	if (user.AuthProtocol != "" && flags&snmpFlagAuth == 0) || (user.PrivProtocol != "" && flags&snmpFlagPriv == 0) {
+		return berReader{}, fmt.Errorf("%w: message for user %q is below the configured security level", ErrSNMPAuth, username)
+	}
+
+	securityLevel := "noAuthNoPriv"
+	var keys *usmKeys
+	if flags&snmpFlagAuth != 0 {
+		if user.AuthProtocol == "" {
+			return berReader{}, fmt.Errorf("%w: user %q has no auth protocol", ErrSNMPAuth, username)
+		}
+		if keys, err = d.localizedKeys(user, engineID); err != nil {
+			return berReader{}, err
+		}
+		if err := verifyUSMDigest(user, keys.auth, data, authParams); err != nil {
+			return berReader{}, err
+		}
+		if err := d.checkTimeliness(user, engineID, boots, engineTime); err != nil {
+			return berReader{}, err
+		}
+		securityLevel = "authNoPriv"
+	}
+
+	var scoped berReader
+	if flags&snmpFlagPriv != 0 {
+		if keys == nil || user.PrivProtocol == "" {
+			return berReader{}, fmt.Errorf("%w: user %q has no priv protocol", ErrSNMPAuth, username)
+		}
+		encrypted, err := msg.expect(berOctetString)
+		if err != nil {
+			return berReader{}, err
+		}
+		plaintext, err := decryptUSM(user.PrivProtocol, keys.priv, privParams, uint32(boots), uint32(engineTime), encrypted.data)
+		if err != nil {
+			return berReader{}, err
+		}
+		decrypted := berReader{data: plaintext}
+		if scoped, err = decrypted.expect(berSequence); err != nil {
+			return berReader{}, fmt.Errorf("%w: decryption produced an invalid scoped pdu", ErrSNMPAuth)
+		}
+		securityLevel = "authPriv"
+	} else if scoped, err = msg.expect(berSequence); err != nil {
+		return berReader{}, err
+	}
+
+	contextEngineID, err := scoped.expect(berOctetString)
+	if err != nil {
+		return berReader{}, err
+	}
+	contextName, err := scoped.expect(berOctetString)
+	if err != nil {
+		return berReader{}, err
+	}
+	pdu, err := scoped.next()
+	if err != nil {
+		return berReader{}, err
+	}
+
+	record["snmp_version"] = "3"
+	record["msg_id"] = msgID.int()
+	record["user"] = username
+	record["security_level"] = securityLevel
+	record["authoritative_engine_id"] = hex.EncodeToString(engineID)
+	record["context_engine_id"] = hex.EncodeToString(contextEngineID.data)
+	record["context_name"] = string(contextName.data)
+	return pdu, nil
+}
+
+// localizedKeys returns the user's auth and priv keys localized to an engine ID (RFC 3414 A.2).
+// Engine IDs come from the packet, so only the cheap localization step runs per new ID;
+// the passphrase keys were derived when the decoder was created.
+func (d *SNMPTrapDecoder) localizedKeys(user SNMPUser, engineID []byte) (*usmKeys, error) {
+	id := usmKeyID{username: user.Username, engineID: string(engineID)}
+
+	d.mu.Lock()
+	if element, ok := d.keys[id]; ok {
+		d.keyOrder.MoveToFront(element)
+		d.mu.Unlock()
+		return element.Value.(*usmKeyEntry).keys, nil
+	}
+	d.mu.Unlock()
+
+	master, ok := d.masters[user.Username]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported auth protocol for user %q", ErrSNMPAuth, user.Username)
+	}
+	newHash, _, _ := usmAuthHash(user.AuthProtocol)
+	keys := &usmKeys{auth: localizeKey(newHash, master.auth, engineID)}
+	if master.priv != nil {
+		keys.priv = localizeKey(newHash, master.priv, engineID)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if element, ok := d.keys[id]; ok {
+		// Another datagram localized the same keys meanwhile
+		d.keyOrder.MoveToFront(element)
+		return element.Value.(*usmKeyEntry).keys, nil
+	}
+	d.keys[id] = d.keyOrder.PushFront(&usmKeyEntry{id: id, keys: keys})
+	if d.keyOrder.Len() > usmMaxCachedKeys {
+		oldest := d.keyOrder.Back()
+		d.keyOrder.Remove(oldest)
+		delete(d.keys, oldest.Value.(*usmKeyEntry).id)
+	}
+	return keys, nil
+}
+
+// checkTimeliness applies the RFC 3414 section 3.2 step 7b time window to an
+// authenticated message, then records its boots/time if they are the latest seen.
+// Replays inside the window are not detected, and the state is lost when the
+// key cache entry is evicted.
+func (d *SNMPTrapDecoder) checkTimeliness(user SNMPUser, engineID []byte, boots, engineTime int64) error {
+	if boots < 0 || boots >= usmMaxEngineBoots || engineTime < 0 {
+		return fmt.Errorf("%w: message for user %q is not in the time window", ErrSNMPAuth, user.Username)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	element, ok := d.keys[usmKeyID{username: user.Username, engineID: string(engineID)}]
+	if !ok {
+		return nil
+	}
+
+	entry := element.Value.(*usmKeyEntry)
+	if entry.seen {
+		if boots < entry.boots || (boots == entry.boots && engineTime < entry.engineTime-usmTimeWindow) {
+			return fmt.Errorf("%w: message for user %q is not in the time window", ErrSNMPAuth, user.Username)
+		}
+		if boots == entry.boots && engineTime <= entry.engineTime {
+			return nil
+		}
+	}
+	entry.seen, entry.boots, entry.engineTime = true, boots, engineTime
+	return nil
+}
+
+// passphraseKeys derives a user's unlocalized auth and priv keys (Ku)
+func passphraseKeys(newHash func() hash.Hash, user SNMPUser) usmKeys {
+	keys := usmKeys{auth: passphraseKey(newHash, user.AuthPassphrase)}
+	if user.PrivProtocol != "" {
+		keys.priv = passphraseKey(newHash, user.PrivPassphrase)
+	}
+	return keys
+}
+
+// usmAuthHash returns the hash and truncated digest length of an auth protocol
+func usmAuthHash(protocol string) (func() hash.Hash, int, bool) {
+	switch strings.ToLower(protocol) {
+	case SNMPAuthMD5:
+		return md5.New, 12, true
+	case SNMPAuthSHA:
+		return sha1.New, 12, true
+	case SNMPAuthSHA224:
+		return sha256.New224, 16, true
+	case SNMPAuthSHA256:
+		return sha256.New, 24, true
+	case SNMPAuthSHA384:
+		return sha512.New384, 32, true
+	case SNMPAuthSHA512:
+		return sha512.New, 48, true
+	}
+	return nil, 0, false
+}
+
+// passphraseKey hashes the passphrase repeated to 1MB
+func passphraseKey(newHash func() hash.Hash, passphrase string) []byte {
+	if passphrase == "" {
+		return nil
+	}
+	h := newHash()
+	buf := make([]byte, 64)
+	for i := 0; i < 1048576; i += len(buf) {
+		for j := range buf {
+			buf[j] = passphrase[(i+j)%len(passphrase)]
+		}
+		h.Write(buf)
+	}
+	return h.Sum(nil)
+}
+
+// localizeKey hashes a passphrase key around the engine ID
+func localizeKey(newHash func() hash.Hash, ku, engineID []byte) []byte {
+	h := newHash()
+	h.Write(ku)
+	h.Write(engineID)
+	h.Write(ku)
+	return h.Sum(nil)
+}
+
+// verifyUSMDigest checks the HMAC of the whole message with the auth parameters zeroed
+func verifyUSMDigest(user SNMPUser, key, data []byte, authParams berReader) error {
+	newHash, digestLen, _ := usmAuthHash(user.AuthProtocol)
+	if len(authParams.data) != digestLen {
+		return fmt.Errorf("%w: invalid digest length for user %q", ErrSNMPAuth, user.Username)
+	}
+
+	message := make([]byte, len(data))
+	copy(message, data)
+	clear(message[authParams.offset : authParams.offset+digestLen])
+
+	mac := hmac.New(newHash, key)
+	mac.Write(message)
+	if !hmac.Equal(mac.Sum(nil)[:digestLen], authParams.data) {
+		return fmt.Errorf("%w: wrong digest for user %q", ErrSNMPAuth, user.Username)
+	}
+	return nil
+}
+
+// decryptUSM decrypts a scoped PDU with DES-CBC (RFC 3414) or AES-128-CFB (RFC 3826)
+func decryptUSM(protocol string, key, salt []byte, boots, engineTime uint32, data []byte) ([]byte, error) {
+	if len(salt) != 8 {
+		return nil, fmt.Errorf("%w: invalid privacy parameters", ErrSNMPAuth)
+	}
+
+	switch strings.ToLower(protocol) {
+	case SNMPPrivDES:
+		if len(key) < 16 || len(data)%des.BlockSize != 0 {
+			return nil, fmt.Errorf("%w: invalid des ciphertext", ErrSNMPAuth)
+		}
+		block, err := des.NewCipher(key[:8])
+		if err != nil {
+			return nil, err
+		}
+		iv := make([]byte, des.BlockSize)
+		for i := range iv {
+			iv[i] = key[8+i] ^ salt[i]
+		}
+		plaintext := make([]byte, len(data))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, data)
+		return plaintext, nil
+
+	case SNMPPrivAES:
+		block, err := aes.NewCipher(key[:16])
+		if err != nil {
+			return nil, err
+		}
+		iv := make([]byte, 0, aes.BlockSize)
+		iv = binary.BigEndian.AppendUint32(iv, boots)
+		iv = binary.BigEndian.AppendUint32(iv, engineTime)
+		iv = append(iv, salt...)
+		return cfbDecrypt(block, iv, data), nil
+	}
+
+	return nil, fmt.Errorf("%w: unsupported priv protocol %q", ErrSNMPAuth, protocol)
+}
+
+// cfbDecrypt decrypts full-block CFB (CFB-128) ciphertext
+func cfbDecrypt(block cipher.Block, iv, data []byte) []byte {
+	plaintext := make([]byte, len(data))
+	feedback := make([]byte, block.BlockSize())
+	copy(feedback, iv)
+	keystream := make([]byte, block.BlockSize())
+
+	for i := 0; i < len(data); i += block.BlockSize() {
+		block.Encrypt(keystream, feedback)
+		end := min(i+block.BlockSize(), len(data))
+		for j := i; j < end; j++ {
+			plaintext[j] = data[j] ^ keystream[j-i]
+		}
+		copy(feedback, data[i:end])
+	}
+	return plaintext
+}
@@ -6,11 +6,16 @@ import (
 
 // UDPMessage represents a single UDP message received
 type UDPMessage struct {
-	Data      []byte
-	From      string
-	Timestamp time.Time
-	TenantID  string
-	DatasetID string
+	Data        []byte
+	From        string
+	Timestamp   time.Time
+	TenantID    string
+	DatasetID   string
+	Format      string // Payload format declared by the listener (e.g. "syslog")
+	JSONMode    string // How JSON payloads are re-encoded (listener json_mode)
+	OnBatched   func() // Optional: called once the message has been added to a batch
+	OnDelivered func() // Optional: called once the message's batch has been forwarded or spooled
+	OnFailed    func() // Optional: called when the message's batch could be neither forwarded nor spooled
 }
 
 // DataBatch represents a batch of UDP messages ready for forwarding
@@ -28,15 +33,51 @@ type DataBatch struct {
 
 // ProxyStats represents proxy processing statistics
 type ProxyStats struct {
-	UDPMessagesReceived int64
-	UDPMessageErrors    int64
-	BatchesCreated      int64
-	BatchesForwarded    int64
-	ForwardingErrors    int64
-	BytesReceived       int64
-	BytesForwarded      int64
-	LastActivity        time.Time
-	UptimeSeconds       int64
+	UDPMessagesReceived     int64
+	UDPMessageErrors        int64
+	BatchesCreated          int64
+	BatchesForwarded        int64
+	ForwardingErrors        int64
+	BytesReceived           int64
+	BytesForwarded          int64
+	HTTPRecordsReceived     int64
+	HTTPRequestsDenied      int64
+	ParseErrors             int64
+	GELFChunkSetsExpired    int64
+	GELFChunkErrors         int64
+	NetFlowTemplateMisses   int64
+	NetFlowTemplatesEvicted int64
+	AcksSent                int64
+	OTLPRecordsReceived     int64
+	OTLPRequestsRejected    int64
+	SNMPAuthFailures        int64
+	SNMPInformsUnacked      int64
+	MultilineEvents         int64
+	DatagramsTruncated      int64
+	ProxyProtocolRejected   int64
+	PipelineDropped         int64
+	LastActivity            time.Time
+	UptimeSeconds           int64
+}
+
+// ListenerStatus is the runtime state of one configured listener
+type ListenerStatus struct {
+	Index          int // Position in udp.listeners
+	DatasetID      string
+	Port           int
+	Path           string
+	BoundAddresses []string // Local addresses of the open sockets
+	KernelDrops    *int64   // Datagrams dropped by the kernel (full receive buffer); nil where unavailable
+	Pipeline       []StageStatus
+}
+
+// StageStatus is the runtime state of one pipeline stage
+type StageStatus struct {
+	Type      string
+	Processed int64 // Messages the stage ran on
+	Dropped   int64 // Messages the stage removed from the stream
+	Errors    int64 // Messages the stage failed on; they continue unchanged
+	LastError string
 }
 
 // ReceiverConfig represents configuration for forwarding to bytefreezer-receiver
@@ -10,6 +10,7 @@ import (
 	"sync"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Time zones for pipeline timestamp stages in images without zoneinfo
 
 	"github.com/n0needt0/bytefreezer-proxy/api"
 	"github.com/n0needt0/bytefreezer-proxy/config"
@@ -105,6 +106,21 @@ func main() {
 		}
 	}()
 
+	// Create the UDP listener up front so the API can push records into its pipeline
+	var udpListener *udp.Listener
+	if cfg.UDP.Enabled {
+		udpListener = udp.NewListener(svcs, &cfg)
+		svcs.Ingestor = udpListener
+		svcs.Listeners = udpListener
+	}
+
+	// Export received/dropped counters as OTEL metrics
+	if cfg.Otel.Enabled {
+		if err := registerStatsMetrics(svcs); err != nil {
+			log.Errorf("Failed to register metrics: %v", err)
+		}
+	}
+
 	// Create and start API server
 	apiServer := api.NewAPIServer(svcs, &cfg)
 	router := apiServer.NewRouter()
@@ -119,11 +135,8 @@ func main() {
 		apiServer.Serve(address, router)
 	}()
 
-	// Create and start UDP listener if enabled
-	var udpListener *udp.Listener
-	if cfg.UDP.Enabled {
-		udpListener = udp.NewListener(svcs, &cfg)
-
+	// Start UDP listener if enabled
+	if udpListener != nil {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/services"
 	"github.com/n0needt0/go-goodies/log"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/metric"
@@ -129,3 +131,73 @@ func setupMetrics(ctx context.Context, cfg *config.Config, res *resource.Resourc
 func GetMeter() metric.Meter {
 	return otel.Meter("bytefreezer-proxy")
 }
+
+// registerStatsMetrics exports the proxy counters as observable OTEL metrics,
+// with kernel drops reported per listener and pipeline counters per stage
+func registerStatsMetrics(svcs *services.Services) error {
+	meter := GetMeter()
+
+	received, err := meter.Int64ObservableCounter("bytefreezer_proxy_messages_received",
+		metric.WithDescription("Messages accepted into the batching pipeline"))
+	if err != nil {
+		return fmt.Errorf("failed to create received counter: %w", err)
+	}
+
+	dropped, err := meter.Int64ObservableCounter("bytefreezer_proxy_message_errors",
+		metric.WithDescription("Messages dropped or failed in the proxy"))
+	if err != nil {
+		return fmt.Errorf("failed to create error counter: %w", err)
+	}
+
+	kernelDrops, err := meter.Int64ObservableCounter("bytefreezer_proxy_kernel_drops",
+		metric.WithDescription("Datagrams dropped by the kernel because a socket receive buffer was full"))
+	if err != nil {
+		return fmt.Errorf("failed to create kernel drop counter: %w", err)
+	}
+
+	stageEvents, err := meter.Int64ObservableCounter("bytefreezer_proxy_pipeline_stage_events",
+		metric.WithDescription("Messages processed, dropped or failed by each listener pipeline stage"))
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline stage counter: %w", err)
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
+		stats := svcs.GetStats()
+		observer.ObserveInt64(received, stats.UDPMessagesReceived)
+		observer.ObserveInt64(dropped, stats.UDPMessageErrors)
+
+		if svcs.Listeners == nil {
+			return nil
+		}
+		for _, status := range svcs.Listeners.ListenerStatuses() {
+			for i, stage := range status.Pipeline {
+				for outcome, count := range map[string]int64{
+					"processed": stage.Processed,
+					"dropped":   stage.Dropped,
+					"errors":    stage.Errors,
+				} {
+					observer.ObserveInt64(stageEvents, count, metric.WithAttributes(
+						attribute.String("dataset_id", status.DatasetID),
+						attribute.Int("port", status.Port),
+						attribute.Int("stage", i+1),
+						attribute.String("type", stage.Type),
+						attribute.String("outcome", outcome),
+					))
+				}
+			}
+
+			if status.KernelDrops == nil {
+				continue
+			}
+			observer.ObserveInt64(kernelDrops, *status.KernelDrops, metric.WithAttributes(
+				attribute.String("dataset_id", status.DatasetID),
+				attribute.Int("port", status.Port),
+			))
+		}
+		return nil
+	}, received, dropped, kernelDrops, stageEvents)
+	if err != nil {
+		return fmt.Errorf("failed to register metrics callback: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package parsers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// cefHeaderFields is the number of pipe separated fields after "CEF:"
+const cefHeaderFields = 8
+
+// CEFMessage represents a parsed ArcSight Common Event Format event
+type CEFMessage struct {
+	Version       string            `json:"cef_version"`
+	DeviceVendor  string            `json:"device_vendor"`
+	DeviceProduct string            `json:"device_product"`
+	DeviceVersion string            `json:"device_version"`
+	SignatureID   string            `json:"signature_id"`
+	Name          string            `json:"name"`
+	Severity      string            `json:"severity"`
+	Extensions    map[string]string `json:"extensions,omitempty"`
+}
+
+// ParseCEF parses a CEF event. Text before "CEF:", such as a syslog header, is skipped.
+func ParseCEF(data []byte) (*CEFMessage, error) {
+	start := bytes.Index(data, []byte("CEF:"))
+	if start < 0 {
+		return nil, errors.New("missing CEF header")
+	}
+	text := strings.TrimRight(string(data[start+len("CEF:"):]), "\r\n\x00")
+
+	header, extension, err := splitCEFHeader(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CEFMessage{
+		Version:       header[0],
+		DeviceVendor:  header[1],
+		DeviceProduct: header[2],
+		DeviceVersion: header[3],
+		SignatureID:   header[4],
+		Name:          header[5],
+		Severity:      header[6],
+		Extensions:    parseCEFExtension(extension),
+	}, nil
+}
+
+// splitCEFHeader splits the header at unescaped pipes, unescaping "\|" and "\\"
+func splitCEFHeader(text string) ([]string, string, error) {
+	fields := make([]string, 0, cefHeaderFields-1)
+	var field strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text) && (text[i+1] == '|' || text[i+1] == '\\'):
+			field.WriteByte(text[i+1])
+			i++
+		case c == '|':
+			fields = append(fields, field.String())
+			field.Reset()
+			if len(fields) == cefHeaderFields-1 {
+				return fields, text[i+1:], nil
+			}
+		default:
+			field.WriteByte(c)
+		}
+	}
+	return nil, "", fmt.Errorf("CEF header has %d of %d fields", len(fields), cefHeaderFields-1)
+}
+
+// parseCEFExtension parses space separated key=value pairs. Values may contain
+// spaces, so a value ends where the next "key=" begins.
+func parseCEFExtension(extension string) map[string]string {
+	// Find the key of each unescaped '='; one with no space since the previous
+	// key is a literal '=' inside a value
+	type cefKey struct{ start, equals int }
+	var keys []cefKey
+	for i := 0; i < len(extension); i++ {
+		if extension[i] == '\\' {
+			i++ // Skip the escaped character
+			continue
+		}
+		if extension[i] != '=' {
+			continue
+		}
+		segment := 0
+		if len(keys) > 0 {
+			segment = keys[len(keys)-1].equals + 1
+		}
+		space := strings.LastIndexByte(extension[segment:i], ' ')
+		if space < 0 && len(keys) > 0 {
+			continue
+		}
+		keys = append(keys, cefKey{start: segment + space + 1, equals: i})
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	pairs := make(map[string]string, len(keys))
+	for i, key := range keys {
+		end := len(extension)
+		if i+1 < len(keys) {
+			end = keys[i+1].start
+		}
+		if name := extension[key.start:key.equals]; name != "" {
+			pairs[name] = unescapeCEFValue(strings.TrimSpace(extension[key.equals+1 : end]))
+		}
+	}
+	return pairs
+}
+
+// unescapeCEFValue resolves the extension escapes \\, \=, \n and \r
+func unescapeCEFValue(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+
+	var unescaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			unescaped.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n':
+			unescaped.WriteByte('\n')
+		case 'r':
+			unescaped.WriteByte('\r')
+		default:
+			unescaped.WriteByte(value[i])
+		}
+	}
+	return unescaped.String()
+}
@@ -0,0 +1,32 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+)
+
+// ParseCSV parses one CSV record into fields named by columns. Values beyond the
+// declared columns are named column_<n> (counting from 1); missing trailing
+// values are omitted.
+func ParseCSV(data []byte, columns []string, delimiter rune) (map[string]string, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimRight(data, "\r\n\x00")))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	record, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string, len(record))
+	for i, value := range record {
+		name := fmt.Sprintf("column_%d", i+1)
+		if i < len(columns) {
+			name = columns[i]
+		}
+		fields[name] = value
+	}
+	return fields, nil
+}
@@ -0,0 +1,81 @@
+package parsers
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// Separators used by ParseKV when none are configured
+const (
+	DefaultKVFieldSplit = " "
+	DefaultKVValueSplit = "="
+)
+
+// ParseKV parses key/value pairs. Pairs are separated by fieldSplit and keys from
+// values by valueSplit. Values in double or single quotes may contain either
+// separator; the quotes are removed. Tokens without valueSplit are skipped.
+func ParseKV(data []byte, fieldSplit, valueSplit string) (map[string]string, error) {
+	if fieldSplit == "" {
+		fieldSplit = DefaultKVFieldSplit
+	}
+	if valueSplit == "" {
+		valueSplit = DefaultKVValueSplit
+	}
+
+	text := string(bytes.TrimRight(data, "\r\n\x00"))
+	pairs := make(map[string]string)
+	for {
+		for strings.HasPrefix(text, fieldSplit) {
+			text = text[len(fieldSplit):]
+		}
+		sep := strings.Index(text, valueSplit)
+		if sep < 0 {
+			break
+		}
+		if next := strings.Index(text, fieldSplit); next >= 0 && next < sep {
+			text = text[next:] // Token without a value
+			continue
+		}
+
+		key := strings.TrimSpace(text[:sep])
+		text = text[sep+len(valueSplit):]
+
+		var value string
+		if len(text) > 0 && (text[0] == '"' || text[0] == '\'') {
+			value, text = readQuoted(text)
+		} else if end := strings.Index(text, fieldSplit); end >= 0 {
+			value, text = text[:end], text[end:]
+		} else {
+			value, text = text, ""
+		}
+
+		if key != "" {
+			pairs[key] = value
+		}
+	}
+
+	if len(pairs) == 0 {
+		return nil, errors.New("no key/value pairs found")
+	}
+	return pairs, nil
+}
+
+// readQuoted reads a quoted value, resolving escaped quotes and backslashes, and
+// returns it with the remaining text. An unterminated quote runs to the end.
+func readQuoted(text string) (string, string) {
+	quote := text[0]
+	var value strings.Builder
+	for i := 1; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && i+1 < len(text) && (text[i+1] == quote || text[i+1] == '\\'):
+			i++
+			value.WriteByte(text[i])
+		case text[i] == quote:
+			return value.String(), text[i+1:]
+		default:
+			value.WriteByte(text[i])
+		}
+	}
+	return value.String(), ""
+}
@@ -0,0 +1,89 @@
+package parsers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LEEFMessage represents a parsed IBM QRadar Log Event Extended Format event
+type LEEFMessage struct {
+	Version        string            `json:"leef_version"`
+	Vendor         string            `json:"vendor"`
+	Product        string            `json:"product"`
+	ProductVersion string            `json:"product_version"`
+	EventID        string            `json:"event_id"`
+	Attributes     map[string]string `json:"attributes,omitempty"`
+}
+
+// ParseLEEF parses a LEEF 1.0 or 2.0 event. LEEF 1.0 attributes are tab separated;
+// LEEF 2.0 may declare another delimiter as a character or hex value ("^", "x5E").
+// Text before "LEEF:", such as a syslog header, is skipped.
+func ParseLEEF(data []byte) (*LEEFMessage, error) {
+	start := bytes.Index(data, []byte("LEEF:"))
+	if start < 0 {
+		return nil, errors.New("missing LEEF header")
+	}
+	text := strings.TrimRight(string(data[start+len("LEEF:"):]), "\r\n\x00")
+
+	header := strings.SplitN(text, "|", 6)
+	if len(header) < 5 {
+		return nil, fmt.Errorf("LEEF header has %d of 5 fields", len(header))
+	}
+	msg := &LEEFMessage{
+		Version:        header[0],
+		Vendor:         header[1],
+		Product:        header[2],
+		ProductVersion: header[3],
+		EventID:        header[4],
+	}
+	attributes := ""
+	if len(header) == 6 {
+		attributes = header[5]
+	}
+
+	delimiter := "\t"
+	if strings.HasPrefix(msg.Version, "2") {
+		// LEEF 2.0 adds the delimiter as a sixth header field
+		declared, rest, found := strings.Cut(attributes, "|")
+		if !found {
+			return nil, errors.New("LEEF 2.0 header is missing the delimiter field")
+		}
+		if declared != "" {
+			var err error
+			if delimiter, err = leefDelimiter(declared); err != nil {
+				return nil, err
+			}
+		}
+		attributes = rest
+	}
+
+	for _, pair := range strings.Split(attributes, delimiter) {
+		key, value, found := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			continue
+		}
+		if msg.Attributes == nil {
+			msg.Attributes = make(map[string]string)
+		}
+		msg.Attributes[key] = value
+	}
+	return msg, nil
+}
+
+// leefDelimiter decodes a LEEF 2.0 delimiter: one character or a hex code
+// such as "x5E" or "0x5E"
+func leefDelimiter(declared string) (string, error) {
+	if len(declared) == 1 {
+		return declared, nil
+	}
+	hex, found := strings.CutPrefix(strings.TrimPrefix(strings.ToLower(declared), "0"), "x")
+	code, err := strconv.ParseUint(hex, 16, 8)
+	if !found || err != nil {
+		return "", fmt.Errorf("invalid LEEF delimiter %q", declared)
+	}
+	return string(rune(code)), nil
+}
@@ -0,0 +1,278 @@
+package parsers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Syslog wire formats
+const (
+	SyslogRFC3164 = "rfc3164"
+	SyslogRFC5424 = "rfc5424"
+)
+
+// syslogNil is the RFC 5424 NILVALUE
+const syslogNil = "-"
+
+var (
+	facilityNames = []string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+	}
+	severityNames = []string{
+		"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+	}
+)
+
+// SyslogMessage represents a parsed RFC 3164 or RFC 5424 syslog message
+type SyslogMessage struct {
+	Format         string                       `json:"syslog_format"`
+	Priority       int                          `json:"priority"`
+	Facility       int                          `json:"facility"`
+	FacilityName   string                       `json:"facility_name"`
+	Severity       int                          `json:"severity"`
+	SeverityName   string                       `json:"severity_name"`
+	Version        int                          `json:"version,omitempty"`
+	Timestamp      string                       `json:"timestamp,omitempty"`
+	Hostname       string                       `json:"hostname,omitempty"`
+	AppName        string                       `json:"app_name,omitempty"`
+	ProcID         string                       `json:"procid,omitempty"`
+	MsgID          string                       `json:"msgid,omitempty"`
+	StructuredData map[string]map[string]string `json:"structured_data,omitempty"`
+	Message        string                       `json:"message"`
+}
+
+// ParseSyslog parses an RFC 5424 or RFC 3164 syslog message.
+// now is used to complete RFC 3164 timestamps, which carry no year or zone.
+func ParseSyslog(data []byte, now time.Time) (*SyslogMessage, error) {
+	data = bytes.TrimRight(data, "\r\n\x00")
+
+	priority, rest, err := parsePriority(data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &SyslogMessage{
+		Priority:     priority,
+		Facility:     priority / 8,
+		FacilityName: facilityNames[priority/8],
+		Severity:     priority % 8,
+		SeverityName: severityNames[priority%8],
+	}
+
+	// RFC 5424 messages carry a non-zero version right after PRI. An RFC 3164 body may
+	// also start with a number, so a message that fails to parse as RFC 5424 is read
+	// as RFC 3164 instead.
+	if len(rest) >= 2 && rest[0] >= '1' && rest[0] <= '9' {
+		if sp := bytes.IndexByte(rest, ' '); sp > 0 && sp <= 3 {
+			if version, err := strconv.Atoi(string(rest[:sp])); err == nil {
+				candidate := *msg
+				candidate.Format = SyslogRFC5424
+				candidate.Version = version
+				if err := parseRFC5424(&candidate, rest[sp+1:]); err == nil {
+					return &candidate, nil
+				}
+			}
+		}
+	}
+
+	msg.Format = SyslogRFC3164
+	parseRFC3164(msg, rest, now)
+	return msg, nil
+}
+
+// parsePriority parses the leading "<PRI>" of a syslog message
+func parsePriority(data []byte) (int, []byte, error) {
+	if len(data) < 3 || data[0] != '<' {
+		return 0, nil, errors.New("missing syslog priority")
+	}
+
+	end := bytes.IndexByte(data[:min(len(data), 5)], '>')
+	if end < 2 {
+		return 0, nil, errors.New("invalid syslog priority")
+	}
+
+	priority, err := strconv.Atoi(string(data[1:end]))
+	if err != nil || priority < 0 || priority > 191 {
+		return 0, nil, fmt.Errorf("invalid syslog priority %q", data[1:end])
+	}
+
+	return priority, data[end+1:], nil
+}
+
+// parseRFC5424 parses the header fields, structured data and message after VERSION SP
+func parseRFC5424(msg *SyslogMessage, data []byte) error {
+	fields := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		sp := bytes.IndexByte(data, ' ')
+		if sp < 0 && i == 4 && len(data) > 0 {
+			// The header may end at MSGID, leaving empty STRUCTURED-DATA and MSG
+			fields = append(fields, string(data))
+			data = nil
+			break
+		}
+		if sp < 0 {
+			return fmt.Errorf("rfc5424 header truncated after %d fields", len(fields))
+		}
+		fields = append(fields, string(data[:sp]))
+		data = data[sp+1:]
+	}
+
+	if fields[0] != syslogNil {
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return fmt.Errorf("invalid rfc5424 timestamp %q", fields[0])
+		}
+		msg.Timestamp = ts.Format(time.RFC3339Nano)
+	}
+	msg.Hostname = nilToEmpty(fields[1])
+	msg.AppName = nilToEmpty(fields[2])
+	msg.ProcID = nilToEmpty(fields[3])
+	msg.MsgID = nilToEmpty(fields[4])
+
+	sd, rest, err := parseStructuredData(data)
+	if err != nil {
+		return err
+	}
+	msg.StructuredData = sd
+
+	rest = bytes.TrimPrefix(rest, []byte(" "))
+	rest = bytes.TrimPrefix(rest, []byte("\xef\xbb\xbf")) // UTF-8 BOM
+	msg.Message = string(rest)
+	return nil
+}
+
+// parseStructuredData parses RFC 5424 STRUCTURED-DATA and returns the remaining bytes
+func parseStructuredData(data []byte) (map[string]map[string]string, []byte, error) {
+	if len(data) == 0 {
+		return nil, data, nil
+	}
+	if data[0] == '-' {
+		return nil, data[1:], nil
+	}
+	if data[0] != '[' {
+		return nil, nil, errors.New("invalid rfc5424 structured data")
+	}
+
+	sd := make(map[string]map[string]string)
+	for len(data) > 0 && data[0] == '[' {
+		data = data[1:]
+
+		end := bytes.IndexAny(data, " ]")
+		if end <= 0 {
+			return nil, nil, errors.New("invalid rfc5424 SD-ID")
+		}
+		id := string(data[:end])
+		params := make(map[string]string)
+		data = data[end:]
+
+		for len(data) > 0 && data[0] == ' ' {
+			data = data[1:]
+
+			eq := bytes.IndexByte(data, '=')
+			if eq <= 0 || eq+1 >= len(data) || data[eq+1] != '"' {
+				return nil, nil, fmt.Errorf("invalid rfc5424 SD-PARAM in %q", id)
+			}
+			name := string(data[:eq])
+			data = data[eq+2:]
+
+			var value strings.Builder
+			closed := false
+			for i := 0; i < len(data); i++ {
+				c := data[i]
+				if c == '\\' && i+1 < len(data) && (data[i+1] == '"' || data[i+1] == '\\' || data[i+1] == ']') {
+					value.WriteByte(data[i+1])
+					i++
+					continue
+				}
+				if c == '"' {
+					data = data[i+1:]
+					closed = true
+					break
+				}
+				value.WriteByte(c)
+			}
+			if !closed {
+				return nil, nil, fmt.Errorf("unterminated rfc5424 SD-PARAM %q", name)
+			}
+			params[name] = value.String()
+		}
+
+		if len(data) == 0 || data[0] != ']' {
+			return nil, nil, fmt.Errorf("unterminated rfc5424 SD-ELEMENT %q", id)
+		}
+		data = data[1:]
+		sd[id] = params
+	}
+
+	return sd, data, nil
+}
+
+// parseRFC3164 leniently parses "TIMESTAMP HOSTNAME TAG[PID]: MSG"; any part may be missing
+func parseRFC3164(msg *SyslogMessage, data []byte, now time.Time) {
+	rest := data
+
+	if ts, n, ok := parseRFC3164Timestamp(rest, now); ok {
+		msg.Timestamp = ts.Format(time.RFC3339Nano)
+		rest = bytes.TrimLeft(rest[n:], " ")
+
+		// The hostname follows the timestamp unless the next token is already the tag
+		if sp := bytes.IndexByte(rest, ' '); sp > 0 && !isTag(rest[:sp]) {
+			msg.Hostname = string(rest[:sp])
+			rest = rest[sp+1:]
+		}
+	}
+
+	if sp := bytes.IndexByte(rest, ' '); sp > 0 && isTag(rest[:sp]) {
+		tag := bytes.TrimSuffix(rest[:sp], []byte(":"))
+		if open := bytes.IndexByte(tag, '['); open > 0 && tag[len(tag)-1] == ']' {
+			msg.ProcID = string(tag[open+1 : len(tag)-1])
+			tag = tag[:open]
+		}
+		msg.AppName = string(tag)
+		rest = rest[sp+1:]
+	}
+
+	msg.Message = string(rest)
+}
+
+// parseRFC3164Timestamp parses "Mmm dd hh:mm:ss" or an RFC 3339 timestamp
+func parseRFC3164Timestamp(data []byte, now time.Time) (time.Time, int, bool) {
+	const stampLen = len(time.Stamp)
+	if len(data) >= stampLen {
+		if ts, err := time.ParseInLocation(time.Stamp, string(data[:stampLen]), now.Location()); err == nil {
+			ts = ts.AddDate(now.Year(), 0, 0)
+			// Messages from late December received in January belong to the previous year
+			if ts.After(now.AddDate(0, 1, 0)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			return ts, stampLen, true
+		}
+	}
+
+	if sp := bytes.IndexByte(data, ' '); sp > 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, string(data[:sp])); err == nil {
+			return ts, sp, true
+		}
+	}
+
+	return time.Time{}, 0, false
+}
+
+// isTag reports whether a token looks like an RFC 3164 TAG ("app:" or "app[123]:")
+func isTag(token []byte) bool {
+	return len(token) > 1 && token[len(token)-1] == ':'
+}
+
+// nilToEmpty maps the RFC 5424 NILVALUE to an empty string
+func nilToEmpty(value string) string {
+	if value == syslogNil {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,108 @@
+package parsers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSyslog(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 30, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    *SyslogMessage
+		wantErr bool
+	}{
+		{
+			name:  "rfc5424",
+			input: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application"] An application event log entry...`,
+			want: &SyslogMessage{
+				Format: SyslogRFC5424, Priority: 165, Facility: 20, FacilityName: "local4", Severity: 5, SeverityName: "notice",
+				Version: 1, Timestamp: "2003-10-11T22:14:15.003Z", Hostname: "mymachine.example.com", AppName: "evntslog", MsgID: "ID47",
+				StructuredData: map[string]map[string]string{"exampleSDID@32473": {"iut": "3", "eventSource": "Application"}},
+				Message:        "An application event log entry...",
+			},
+		},
+		{
+			name:  "rfc5424 nil values and BOM",
+			input: "<34>1 - - - - - - \xef\xbb\xbf'su root' failed\n",
+			want: &SyslogMessage{
+				Format: SyslogRFC5424, Priority: 34, Facility: 4, FacilityName: "auth", Severity: 2, SeverityName: "crit",
+				Version: 1, Message: "'su root' failed",
+			},
+		},
+		{
+			name:  "rfc5424 header ending at MSGID",
+			input: "<34>1 2025-12-31T23:59:00Z host su 77 ID47",
+			want: &SyslogMessage{
+				Format: SyslogRFC5424, Priority: 34, Facility: 4, FacilityName: "auth", Severity: 2, SeverityName: "crit",
+				Version: 1, Timestamp: "2025-12-31T23:59:00Z", Hostname: "host", AppName: "su", ProcID: "77", MsgID: "ID47",
+			},
+		},
+		{
+			name:  "rfc5424 header ending at nil MSGID",
+			input: "<34>1 - host su - -\r\n",
+			want: &SyslogMessage{
+				Format: SyslogRFC5424, Priority: 34, Facility: 4, FacilityName: "auth", Severity: 2, SeverityName: "crit",
+				Version: 1, Hostname: "host", AppName: "su",
+			},
+		},
+		{
+			name:  "rfc5424 structured data without message",
+			input: `<34>1 - host su - ID47 [origin ip="192.0.2.1"]`,
+			want: &SyslogMessage{
+				Format: SyslogRFC5424, Priority: 34, Facility: 4, FacilityName: "auth", Severity: 2, SeverityName: "crit",
+				Version: 1, Hostname: "host", AppName: "su", MsgID: "ID47",
+				StructuredData: map[string]map[string]string{"origin": {"ip": "192.0.2.1"}},
+			},
+		},
+		{
+			name:  "truncated rfc5424 header falls back to rfc3164",
+			input: "<34>1 - host su",
+			want: &SyslogMessage{
+				Format: SyslogRFC3164, Priority: 34, Facility: 4, FacilityName: "auth", Severity: 2, SeverityName: "crit",
+				Message: "1 - host su",
+			},
+		},
+		{
+			name:  "rfc3164",
+			input: "<13>Dec 31 23:59:00 host app[42]: hello",
+			want: &SyslogMessage{
+				Format: SyslogRFC3164, Priority: 13, Facility: 1, FacilityName: "user", Severity: 5, SeverityName: "notice",
+				Timestamp: "2025-12-31T23:59:00Z", Hostname: "host", AppName: "app", ProcID: "42", Message: "hello",
+			},
+		},
+		{
+			name:  "rfc3164 body starting with a number",
+			input: "<13>3 errors found",
+			want: &SyslogMessage{
+				Format: SyslogRFC3164, Priority: 13, Facility: 1, FacilityName: "user", Severity: 5, SeverityName: "notice",
+				Message: "3 errors found",
+			},
+		},
+		{
+			name:    "missing priority",
+			input:   "hello",
+			wantErr: true,
+		},
+		{
+			name:    "priority out of range",
+			input:   "<192>1 - - - - - -",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSyslog([]byte(tt.input), now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSyslog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSyslog() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
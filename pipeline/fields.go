@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// defaultField holds the payload text of enveloped (non-JSON) messages
+const defaultField = "message"
+
+// getField looks up a field. Dots step into nested objects unless the record has
+// a key containing the dots itself (e.g. OTLP "service.name").
+func getField(fields map[string]interface{}, path string) (interface{}, bool) {
+	if value, ok := fields[path]; ok {
+		return value, true
+	}
+	head, rest, found := strings.Cut(path, ".")
+	if !found {
+		return nil, false
+	}
+	child, ok := fields[head].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return getField(child, rest)
+}
+
+// setField sets a field, creating nested objects along a dotted path
+func setField(fields map[string]interface{}, path string, value interface{}) {
+	if _, ok := fields[path]; ok || !strings.Contains(path, ".") {
+		fields[path] = value
+		return
+	}
+	head, rest, _ := strings.Cut(path, ".")
+	child, ok := fields[head].(map[string]interface{})
+	if !ok {
+		child = make(map[string]interface{})
+		fields[head] = child
+	}
+	setField(child, rest, value)
+}
+
+// deleteField removes a field and reports whether it existed
+func deleteField(fields map[string]interface{}, path string) bool {
+	if _, ok := fields[path]; ok {
+		delete(fields, path)
+		return true
+	}
+	head, rest, found := strings.Cut(path, ".")
+	if !found {
+		return false
+	}
+	child, ok := fields[head].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	return deleteField(child, rest)
+}
+
+// valueString renders a field value as text for matching
+func valueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(encoded)
+	default:
+		return fmt.Sprint(v)
+	}
+}
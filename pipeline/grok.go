@@ -0,0 +1,213 @@
+package pipeline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+)
+
+// maxGrokDepth bounds pattern nesting so recursive definitions fail instead of looping
+const maxGrokDepth = 32
+
+// grokReference matches %{SYNTAX}, %{SYNTAX:field} and %{SYNTAX:field:int|float}
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::([^:}]+))?(?::(int|float))?\}`)
+
+// errNoMatch is returned when none of a stage's expressions match
+var errNoMatch = errors.New("no pattern matched")
+
+// grokDefinitions is the bundled library, parsed once
+var grokDefinitions = mustReadGrokDefinitions(grokLibrary)
+
+// capture is the field and type behind a generated grok group name
+type capture struct {
+	field string
+	kind  string // "", "int" or "float"
+}
+
+// extractor matches text against expressions with named groups and turns the
+// groups of the first match into fields
+type extractor struct {
+	expressions []*regexp.Regexp
+	captures    map[string]capture // Generated grok groups; other group names are used as field names
+}
+
+// newRegexParser compiles pattern and patterns as Go regexps with named groups
+func newRegexParser(cfg config.Stage) (textParser, error) {
+	sources := stageExpressions(cfg)
+	if len(sources) == 0 {
+		return nil, errors.New("pattern or patterns is required")
+	}
+
+	e := &extractor{}
+	for _, source := range sources {
+		expression, err := regexp.Compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", source, err)
+		}
+		e.expressions = append(e.expressions, expression)
+	}
+	return e.extract, nil
+}
+
+// newGrokParser expands grok patterns against the bundled library and any
+// pattern_files, which may add or override definitions
+func newGrokParser(cfg config.Stage) (textParser, error) {
+	sources := stageExpressions(cfg)
+	if len(sources) == 0 {
+		return nil, errors.New("pattern or patterns is required")
+	}
+
+	definitions := make(map[string]string, len(grokDefinitions))
+	for name, definition := range grokDefinitions {
+		definitions[name] = definition
+	}
+	for _, path := range cfg.PatternFiles {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open pattern file: %w", err)
+		}
+		custom, err := readGrokDefinitions(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("pattern file %s: %w", path, err)
+		}
+		for name, definition := range custom {
+			definitions[name] = definition
+		}
+	}
+
+	e := &extractor{captures: make(map[string]capture)}
+	for _, source := range sources {
+		expanded, err := e.expandGrok(definitions, source, 0)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", source, err)
+		}
+		expression, err := regexp.Compile(expanded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", source, err)
+		}
+		e.expressions = append(e.expressions, expression)
+	}
+	return e.extract, nil
+}
+
+// expandGrok replaces pattern references with their regexps. References with a
+// field become generated named groups, the others non-capturing groups.
+func (e *extractor) expandGrok(definitions map[string]string, pattern string, depth int) (string, error) {
+	if depth > maxGrokDepth {
+		return "", errors.New("patterns nested too deeply, check for recursive definitions")
+	}
+
+	var expandErr error
+	expanded := grokReference.ReplaceAllStringFunc(pattern, func(reference string) string {
+		if expandErr != nil {
+			return ""
+		}
+		parts := grokReference.FindStringSubmatch(reference)
+		definition, ok := definitions[parts[1]]
+		if !ok {
+			expandErr = fmt.Errorf("unknown grok pattern %s", parts[1])
+			return ""
+		}
+		inner, err := e.expandGrok(definitions, definition, depth+1)
+		if err != nil {
+			expandErr = err
+			return ""
+		}
+
+		if parts[2] == "" {
+			return "(?:" + inner + ")"
+		}
+		name := fmt.Sprintf("grok%d", len(e.captures))
+		e.captures[name] = capture{field: parts[2], kind: parts[3]}
+		return "(?P<" + name + ">" + inner + ")"
+	})
+	return expanded, expandErr
+}
+
+// extract returns the named groups of the first matching expression as fields
+func (e *extractor) extract(text string, _ time.Time) (map[string]interface{}, error) {
+	for _, expression := range e.expressions {
+		match := expression.FindStringSubmatchIndex(text)
+		if match == nil {
+			continue
+		}
+
+		fields := make(map[string]interface{})
+		for i, name := range expression.SubexpNames() {
+			if name == "" || match[2*i] < 0 {
+				continue // Unnamed or did not take part in the match
+			}
+			field, kind := name, ""
+			if c, ok := e.captures[name]; ok {
+				field, kind = c.field, c.kind
+			}
+			setField(fields, field, convertCapture(text[match[2*i]:match[2*i+1]], kind))
+		}
+		return fields, nil
+	}
+	return nil, errNoMatch
+}
+
+// convertCapture applies a grok :int or :float conversion; values that do not
+// convert stay strings
+func convertCapture(value, kind string) interface{} {
+	switch kind {
+	case "int":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
+
+// stageExpressions returns pattern followed by patterns
+func stageExpressions(cfg config.Stage) []string {
+	var sources []string
+	if cfg.Pattern != "" {
+		sources = append(sources, cfg.Pattern)
+	}
+	return append(sources, cfg.Patterns...)
+}
+
+// readGrokDefinitions reads "NAME regexp" lines; blank lines and # comments are skipped
+func readGrokDefinitions(r io.Reader) (map[string]string, error) {
+	definitions := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, definition, found := strings.Cut(text, " ")
+		definition = strings.TrimSpace(definition)
+		if !found || definition == "" {
+			return nil, fmt.Errorf("line %d: expected NAME PATTERN", line)
+		}
+		definitions[name] = definition
+	}
+	return definitions, scanner.Err()
+}
+
+// mustReadGrokDefinitions parses the bundled library
+func mustReadGrokDefinitions(library string) map[string]string {
+	definitions, err := readGrokDefinitions(strings.NewReader(library))
+	if err != nil {
+		panic(err)
+	}
+	return definitions
+}
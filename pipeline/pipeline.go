@@ -0,0 +1,128 @@
+// Package pipeline runs the per-listener processing stages that transform,
+// filter and route records before they are batched
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/domain"
+)
+
+// Stage types
+const (
+	StageParse     = "parse"
+	StageRename    = "rename"
+	StageDrop      = "drop"
+	StageAddFields = "add_fields"
+	StageRedact    = "redact"
+	StageRoute     = "route"
+	StageTimestamp = "timestamp"
+)
+
+// Event is a record moving through a pipeline
+type Event struct {
+	Fields    map[string]interface{} // The record as it would be forwarded
+	TenantID  string
+	DatasetID string
+	Timestamp time.Time // When the message was received
+}
+
+// Processor is the work of one stage. It returns false to drop the event; an error
+// is recorded against the stage and the event continues.
+type Processor interface {
+	Process(event *Event) (bool, error)
+}
+
+// constructors builds the processor for each stage type
+var constructors = map[string]func(config.Stage) (Processor, error){
+	StageParse:     newParseStage,
+	StageRename:    newRenameStage,
+	StageDrop:      newDropStage,
+	StageAddFields: newAddFieldsStage,
+	StageRedact:    newRedactStage,
+	StageRoute:     newRouteStage,
+	StageTimestamp: newTimestampStage,
+}
+
+// stage is a configured processor with its counters
+type stage struct {
+	kind      string
+	processor Processor
+	processed atomic.Int64
+	dropped   atomic.Int64
+	errors    atomic.Int64
+
+	mu        sync.Mutex
+	lastError string
+}
+
+// Pipeline is an ordered list of stages. It is safe for concurrent use.
+type Pipeline struct {
+	stages []*stage
+}
+
+// New builds a pipeline from its configuration. It returns nil for an empty list.
+func New(stages []config.Stage) (*Pipeline, error) {
+	if len(stages) == 0 {
+		return nil, nil
+	}
+
+	p := &Pipeline{}
+	for i, cfg := range stages {
+		kind := strings.ToLower(cfg.Type)
+		constructor, ok := constructors[kind]
+		if !ok {
+			return nil, fmt.Errorf("stage %d: unknown type %q", i+1, cfg.Type)
+		}
+		processor, err := constructor(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("stage %d (%s): %w", i+1, kind, err)
+		}
+		p.stages = append(p.stages, &stage{kind: kind, processor: processor})
+	}
+	return p, nil
+}
+
+// Process runs the event through every stage in order. It returns false when a
+// stage dropped the event.
+func (p *Pipeline) Process(event *Event) bool {
+	for _, s := range p.stages {
+		s.processed.Add(1)
+		keep, err := s.processor.Process(event)
+		if err != nil {
+			s.errors.Add(1)
+			s.mu.Lock()
+			s.lastError = err.Error()
+			s.mu.Unlock()
+		}
+		if !keep {
+			s.dropped.Add(1)
+			return false
+		}
+	}
+	return true
+}
+
+// Stats reports the counters of each stage in order
+func (p *Pipeline) Stats() []domain.StageStatus {
+	statuses := make([]domain.StageStatus, len(p.stages))
+	for i, s := range p.stages {
+		s.mu.Lock()
+		lastError := s.lastError
+		s.mu.Unlock()
+
+		statuses[i] = domain.StageStatus{
+			Type:      s.kind,
+			Processed: s.processed.Load(),
+			Dropped:   s.dropped.Load(),
+			Errors:    s.errors.Load(),
+			LastError: lastError,
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,340 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/domain"
+)
+
+// received is the receive time of every test event
+var received = time.Date(2026, 1, 1, 0, 0, 30, 0, time.UTC)
+
+// decodeRecord decodes a record the way the listener does before running a pipeline
+func decodeRecord(t *testing.T, record string) map[string]interface{} {
+	t.Helper()
+	decoder := json.NewDecoder(strings.NewReader(record))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		t.Fatalf("invalid test record %s: %v", record, err)
+	}
+	return fields
+}
+
+// encodeRecord encodes fields with sorted keys for comparison
+func encodeRecord(t *testing.T, fields map[string]interface{}) string {
+	t.Helper()
+	var output strings.Builder
+	encoder := json.NewEncoder(&output)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(fields); err != nil {
+		t.Fatalf("failed to encode record: %v", err)
+	}
+	return strings.TrimSuffix(output.String(), "\n")
+}
+
+func TestPipelineProcess(t *testing.T) {
+	tests := []struct {
+		name      string
+		stages    []config.Stage
+		record    string
+		want      string // Expected record; ignored when the event is dropped
+		dropped   bool
+		tenantID  string
+		datasetID string
+	}{
+		{
+			name:   "parse json",
+			stages: []config.Stage{{Type: StageParse}},
+			record: `{"message":"{\"level\":\"info\",\"count\":12345678901234567890}","source":"10.0.0.1:514"}`,
+			want:   `{"count":12345678901234567890,"level":"info","source":"10.0.0.1:514"}`,
+		},
+		{
+			name:   "parse json into target",
+			stages: []config.Stage{{Type: StageParse, Format: ParseJSON, Target: "payload"}},
+			record: `{"message":"{\"level\":\"info\"}"}`,
+			want:   `{"message":"{\"level\":\"info\"}","payload":{"level":"info"}}`,
+		},
+		{
+			name:   "parse json failure",
+			stages: []config.Stage{{Type: StageParse}},
+			record: `{"message":"not json"}`,
+			want:   `{"message":"not json","parse_error":"json: invalid character 'o' in literal null (expecting 'u')"}`,
+		},
+		{
+			name:   "parse syslog rfc5424",
+			stages: []config.Stage{{Type: StageParse, Format: ParseSyslog}},
+			record: `{"message":"<34>1 2025-12-31T23:59:00Z host su - ID47 - failed login"}`,
+			want:   `{"app_name":"su","facility":4,"facility_name":"auth","hostname":"host","message":"failed login","msgid":"ID47","priority":34,"severity":2,"severity_name":"crit","syslog_format":"rfc5424","timestamp":"2025-12-31T23:59:00Z","version":1}`,
+		},
+		{
+			name:   "parse syslog rfc5424 header only",
+			stages: []config.Stage{{Type: StageParse, Format: ParseSyslog}},
+			record: `{"message":"<34>1 2025-12-31T23:59:00Z host su - ID47"}`,
+			want:   `{"app_name":"su","facility":4,"facility_name":"auth","hostname":"host","message":"","msgid":"ID47","priority":34,"severity":2,"severity_name":"crit","syslog_format":"rfc5424","timestamp":"2025-12-31T23:59:00Z","version":1}`,
+		},
+		{
+			name:   "parse syslog rfc3164",
+			stages: []config.Stage{{Type: StageParse, Format: ParseSyslog}},
+			record: `{"message":"<13>Dec 31 23:59:00 host app[42]: hello"}`,
+			want:   `{"app_name":"app","facility":1,"facility_name":"user","hostname":"host","message":"hello","priority":13,"procid":"42","severity":5,"severity_name":"notice","syslog_format":"rfc3164","timestamp":"2025-12-31T23:59:00Z"}`,
+		},
+		{
+			name: "parse grok",
+			stages: []config.Stage{{
+				Type:     StageParse,
+				Format:   ParseGrok,
+				Patterns: []string{`%{WORD:method} %{URIPATHPARAM:request}`, `%{IP:client.ip} %{WORD:method} %{NUMBER:bytes:int}`},
+			}},
+			record: `{"message":"10.0.0.1 GET 512"}`,
+			want:   `{"bytes":512,"client":{"ip":"10.0.0.1"},"message":"10.0.0.1 GET 512","method":"GET"}`,
+		},
+		{
+			name:   "parse grok without match",
+			stages: []config.Stage{{Type: StageParse, Format: ParseGrok, Pattern: `%{IP:client}`}},
+			record: `{"message":"no address here"}`,
+			want:   `{"message":"no address here","parse_error":"grok: no pattern matched"}`,
+		},
+		{
+			name:   "parse regex",
+			stages: []config.Stage{{Type: StageParse, Format: ParseRegex, Pattern: `user=(?P<user>\w+)`}},
+			record: `{"message":"login user=alice"}`,
+			want:   `{"message":"login user=alice","user":"alice"}`,
+		},
+		{
+			name:   "parse cef",
+			stages: []config.Stage{{Type: StageParse, Format: ParseCEF}},
+			record: `{"message":"CEF:0|Vendor|Firewall|1.0|100|Blocked|5|src=10.0.0.1 msg=port scan detected"}`,
+			want:   `{"cef_version":"0","device_product":"Firewall","device_vendor":"Vendor","device_version":"1.0","extensions":{"msg":"port scan detected","src":"10.0.0.1"},"name":"Blocked","severity":"5","signature_id":"100"}`,
+		},
+		{
+			name:   "parse leef",
+			stages: []config.Stage{{Type: StageParse, Format: ParseLEEF}},
+			record: `{"message":"LEEF:2.0|Vendor|IDS|2.1|4000|^|src=10.0.0.1^usrName=bob"}`,
+			want:   `{"attributes":{"src":"10.0.0.1","usrName":"bob"},"event_id":"4000","leef_version":"2.0","product":"IDS","product_version":"2.1","vendor":"Vendor"}`,
+		},
+		{
+			name:   "parse kv",
+			stages: []config.Stage{{Type: StageParse, Format: ParseKV, KV: config.KV{FieldSplit: ";", ValueSplit: ":"}}},
+			record: `{"message":"user:alice;src.ip:10.0.0.1"}`,
+			want:   `{"message":"user:alice;src.ip:10.0.0.1","src":{"ip":"10.0.0.1"},"user":"alice"}`,
+		},
+		{
+			name:   "parse csv",
+			stages: []config.Stage{{Type: StageParse, Format: ParseCSV, CSV: config.CSV{Columns: []string{"time", "user"}}}},
+			record: `{"message":"12:00,\"smith, j\",extra"}`,
+			want:   `{"column_3":"extra","time":"12:00","user":"smith, j"}`,
+		},
+		{
+			name:   "rename with dotted paths",
+			stages: []config.Stage{{Type: StageRename, Renames: []config.FieldRename{{From: "src.ip", To: "client.address"}, {From: "missing", To: "other"}}}},
+			record: `{"src":{"ip":"10.0.0.1","port":514}}`,
+			want:   `{"client":{"address":"10.0.0.1"},"src":{"port":514}}`,
+		},
+		{
+			name:    "drop matching",
+			stages:  []config.Stage{{Type: StageDrop, Field: "level", Pattern: `^debug$`}},
+			record:  `{"level":"debug"}`,
+			dropped: true,
+		},
+		{
+			name:   "drop not matching",
+			stages: []config.Stage{{Type: StageDrop, Field: "level", Pattern: `^debug$`}},
+			record: `{"level":"info"}`,
+			want:   `{"level":"info"}`,
+		},
+		{
+			name:   "add fields",
+			stages: []config.Stage{{Type: StageAddFields, Values: []config.FieldValue{{Field: "env", Value: "prod"}, {Field: "meta.site", Value: "dc1"}}}},
+			record: `{"env":"dev"}`,
+			want:   `{"env":"prod","meta":{"site":"dc1"}}`,
+		},
+		{
+			name:   "redact whole values",
+			stages: []config.Stage{{Type: StageRedact, Fields: []string{"password", "user.token"}}},
+			record: `{"password":"secret","user":{"name":"bob","token":12345}}`,
+			want:   `{"password":"[REDACTED]","user":{"name":"bob","token":"[REDACTED]"}}`,
+		},
+		{
+			name:   "redact pattern in every string",
+			stages: []config.Stage{{Type: StageRedact, Pattern: `\d{4}-\d{4}`, Replacement: "****"}},
+			record: `{"message":"card 1234-5678","items":["id 9999-0000",42]}`,
+			want:   `{"items":["id ****",42],"message":"card ****"}`,
+		},
+		{
+			name:      "route matching",
+			stages:    []config.Stage{{Type: StageRoute, Field: "level", Pattern: `^error$`, DatasetID: "errors", TenantID: "ops"}},
+			record:    `{"level":"error"}`,
+			want:      `{"level":"error"}`,
+			tenantID:  "ops",
+			datasetID: "errors",
+		},
+		{
+			name:   "timestamp rfc3339 with offset",
+			stages: []config.Stage{{Type: StageTimestamp}},
+			record: `{"timestamp":"2026-01-01T01:00:00+01:00"}`,
+			want:   `{"@timestamp":"2026-01-01T00:00:00Z","received_at":"2026-01-01T00:00:30Z","timestamp":"2026-01-01T01:00:00+01:00"}`,
+		},
+		{
+			name:   "timestamp epoch milliseconds",
+			stages: []config.Stage{{Type: StageTimestamp, Field: "ts", Target: "time"}},
+			record: `{"ts":1767225600123}`,
+			want:   `{"received_at":"2026-01-01T00:00:30Z","time":"2026-01-01T00:00:00.123Z","ts":1767225600123}`,
+		},
+		{
+			name:   "timestamp layout in time zone",
+			stages: []config.Stage{{Type: StageTimestamp, Layouts: []string{"2006-01-02 15:04:05"}, Timezone: "America/New_York"}},
+			record: `{"timestamp":"2025-12-31 19:00:00"}`,
+			want:   `{"@timestamp":"2026-01-01T00:00:00Z","received_at":"2026-01-01T00:00:30Z","timestamp":"2025-12-31 19:00:00"}`,
+		},
+		{
+			name:   "timestamp syslog completes year",
+			stages: []config.Stage{{Type: StageTimestamp, Layouts: []string{"syslog"}}},
+			record: `{"timestamp":"Dec 31 23:59:00"}`,
+			want:   `{"@timestamp":"2025-12-31T23:59:00Z","received_at":"2026-01-01T00:00:30Z","timestamp":"Dec 31 23:59:00"}`,
+		},
+		{
+			name:   "timestamp clock skew",
+			stages: []config.Stage{{Type: StageTimestamp, MaxSkewSeconds: 60}},
+			record: `{"timestamp":"2025-12-31T23:50:00Z"}`,
+			want:   `{"@timestamp":"2025-12-31T23:50:00Z","clock_skew_seconds":-630,"received_at":"2026-01-01T00:00:30Z","timestamp":"2025-12-31T23:50:00Z"}`,
+		},
+		{
+			name:   "timestamp missing field",
+			stages: []config.Stage{{Type: StageTimestamp}},
+			record: `{"message":"hello"}`,
+			want:   `{"@timestamp":"2026-01-01T00:00:30Z","message":"hello","received_at":"2026-01-01T00:00:30Z"}`,
+		},
+		{
+			name:   "timestamp rejects infinite epoch",
+			stages: []config.Stage{{Type: StageTimestamp}},
+			record: `{"timestamp":"Inf"}`,
+			want:   `{"@timestamp":"2026-01-01T00:00:30Z","received_at":"2026-01-01T00:00:30Z","timestamp":"Inf","timestamp_error":"\"Inf\" matches no timestamp layout"}`,
+		},
+		{
+			name: "stages run in order",
+			stages: []config.Stage{
+				{Type: StageParse},
+				{Type: StageRename, Renames: []config.FieldRename{{From: "msg", To: "message"}}},
+				{Type: StageDrop, Field: "message", Pattern: `^healthcheck`},
+				{Type: StageAddFields, Values: []config.FieldValue{{Field: "env", Value: "prod"}}},
+			},
+			record: `{"message":"{\"msg\":\"user created\"}"}`,
+			want:   `{"env":"prod","message":"user created"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.stages)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			event := &Event{
+				Fields:    decodeRecord(t, tt.record),
+				TenantID:  "tenant",
+				DatasetID: "dataset",
+				Timestamp: received,
+			}
+			if keep := p.Process(event); keep == tt.dropped {
+				t.Fatalf("Process() = %t, want %t", keep, !tt.dropped)
+			}
+			if tt.dropped {
+				return
+			}
+
+			if got := encodeRecord(t, event.Fields); got != tt.want {
+				t.Errorf("record\n got: %s\nwant: %s", got, tt.want)
+			}
+
+			wantTenant, wantDataset := "tenant", "dataset"
+			if tt.tenantID != "" {
+				wantTenant = tt.tenantID
+			}
+			if tt.datasetID != "" {
+				wantDataset = tt.datasetID
+			}
+			if event.TenantID != wantTenant || event.DatasetID != wantDataset {
+				t.Errorf("routed to %s/%s, want %s/%s", event.TenantID, event.DatasetID, wantTenant, wantDataset)
+			}
+		})
+	}
+}
+
+func TestNewInvalidStages(t *testing.T) {
+	tests := []struct {
+		name   string
+		stages []config.Stage
+		want   string
+	}{
+		{"unknown type", []config.Stage{{Type: "enrich"}}, `stage 1: unknown type "enrich"`},
+		{"unknown parse format", []config.Stage{{Type: StageParse, Format: "xml"}}, `stage 1 (parse): unknown format "xml"`},
+		{"invalid regex", []config.Stage{{Type: StageParse, Format: ParseRegex, Pattern: `(`}}, `stage 1 (parse): invalid pattern`},
+		{"unknown grok pattern", []config.Stage{{Type: StageParse, Format: ParseGrok, Pattern: `%{NOPE:x}`}}, `stage 1 (parse): `},
+		{"csv without columns", []config.Stage{{Type: StageParse, Format: ParseCSV}}, `stage 1 (parse): csv columns are required`},
+		{"rename without renames", []config.Stage{{Type: StageParse}, {Type: StageRename}}, `stage 2 (rename): renames are required`},
+		{"drop without pattern", []config.Stage{{Type: StageDrop}}, `stage 1 (drop): pattern is required`},
+		{"add_fields without field", []config.Stage{{Type: StageAddFields, Values: []config.FieldValue{{Value: "x"}}}}, `stage 1 (add_fields): values need a field`},
+		{"redact without fields or pattern", []config.Stage{{Type: StageRedact}}, `stage 1 (redact): fields or pattern is required`},
+		{"route without target", []config.Stage{{Type: StageRoute, Pattern: "x"}}, `stage 1 (route): dataset_id or tenant_id is required`},
+		{"invalid timezone", []config.Stage{{Type: StageTimestamp, Timezone: "Mars/Olympus"}}, `stage 1 (timestamp): invalid timezone`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.stages)
+			if err == nil {
+				t.Fatalf("New() = %v, want error", p)
+			}
+			if !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("New() error = %q, want prefix %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewEmpty(t *testing.T) {
+	p, err := New(nil)
+	if p != nil || err != nil {
+		t.Errorf("New(nil) = %v, %v, want nil, nil", p, err)
+	}
+}
+
+func TestPipelineStats(t *testing.T) {
+	p, err := New([]config.Stage{
+		{Type: StageParse},
+		{Type: StageDrop, Field: "level", Pattern: `^debug$`},
+		{Type: StageAddFields, Values: []config.FieldValue{{Field: "env", Value: "prod"}}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for _, record := range []string{
+		`{"message":"{\"level\":\"info\"}"}`,
+		`{"message":"{\"level\":\"debug\"}"}`,
+		`{"message":"not json"}`,
+	} {
+		p.Process(&Event{Fields: decodeRecord(t, record), Timestamp: received})
+	}
+
+	want := []domain.StageStatus{
+		{Type: StageParse, Processed: 3, Errors: 1, LastError: "invalid character 'o' in literal null (expecting 'u')"},
+		{Type: StageDrop, Processed: 3, Dropped: 1},
+		{Type: StageAddFields, Processed: 2},
+	}
+	got := p.Stats()
+	if len(got) != len(want) {
+		t.Fatalf("Stats() returned %d stages, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("stage %d stats = %+v, want %+v", i+1, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,417 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/parsers"
+)
+
+// Parse stage formats
+const (
+	ParseJSON   = "json"
+	ParseSyslog = "syslog"
+	ParseCEF    = "cef"
+	ParseLEEF   = "leef"
+	ParseKV     = "kv"
+	ParseCSV    = "csv"
+	ParseRegex  = "regex"
+	ParseGrok   = "grok"
+)
+
+// defaultReplacement masks redacted values
+const defaultReplacement = "[REDACTED]"
+
+// textParser turns the text of a field into structured fields
+type textParser func(text string, received time.Time) (map[string]interface{}, error)
+
+// parseFormat builds the parser of one parse stage format
+type parseFormat struct {
+	build    func(config.Stage) (textParser, error)
+	keepText bool // Fields are extracted from the text, which is kept
+}
+
+// parseFormats lists the parse stage formats
+var parseFormats = map[string]parseFormat{
+	ParseJSON:   {build: func(config.Stage) (textParser, error) { return parseJSON, nil }},
+	ParseSyslog: {build: func(config.Stage) (textParser, error) { return parseSyslog, nil }},
+	ParseCEF:    {build: func(config.Stage) (textParser, error) { return parseCEF, nil }},
+	ParseLEEF:   {build: func(config.Stage) (textParser, error) { return parseLEEF, nil }},
+	ParseKV:     {build: newKVParser, keepText: true},
+	ParseCSV:    {build: newCSVParser},
+	ParseRegex:  {build: newRegexParser, keepText: true},
+	ParseGrok:   {build: newGrokParser, keepText: true},
+}
+
+// parseStage parses the text of one field into structured fields
+type parseStage struct {
+	format   string
+	field    string
+	target   string
+	keepText bool
+	parse    textParser
+}
+
+func newParseStage(cfg config.Stage) (Processor, error) {
+	format := strings.ToLower(cfg.Format)
+	if format == "" {
+		format = ParseJSON
+	}
+	parser, ok := parseFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q", cfg.Format)
+	}
+	parse, err := parser.build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &parseStage{
+		format:   format,
+		field:    fieldOrDefault(cfg.Field),
+		target:   cfg.Target,
+		keepText: parser.keepText,
+		parse:    parse,
+	}, nil
+}
+
+// Process merges the parsed fields into the top level, or stores them under the
+// target. JSON and syslog text is replaced by its fields, while text that fields
+// were extracted from is kept. Failures are noted in parse_error and the record
+// is otherwise left as it was.
+func (s *parseStage) Process(event *Event) (bool, error) {
+	value, ok := getField(event.Fields, s.field)
+	text, isText := value.(string)
+	if !ok || !isText {
+		return true, nil // Missing or already structured
+	}
+
+	parsed, err := s.parse(text, event.Timestamp)
+	if err != nil {
+		event.Fields["parse_error"] = s.format + ": " + err.Error()
+		return true, err
+	}
+
+	if s.target != "" {
+		setField(event.Fields, s.target, parsed)
+		return true, nil
+	}
+	if !s.keepText {
+		deleteField(event.Fields, s.field)
+	}
+	for name, value := range parsed {
+		event.Fields[name] = value
+	}
+	return true, nil
+}
+
+// parseJSON decodes a JSON object, keeping numbers exact
+func parseJSON(text string, _ time.Time) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("trailing data after JSON object")
+	}
+	if fields == nil {
+		return nil, errors.New("not a JSON object")
+	}
+	return fields, nil
+}
+
+// parseSyslog parses an RFC 3164 or RFC 5424 message
+func parseSyslog(text string, received time.Time) (map[string]interface{}, error) {
+	parsed, err := parsers.ParseSyslog([]byte(text), received)
+	if err != nil {
+		return nil, err
+	}
+	return toFields(parsed)
+}
+
+// parseCEF parses an ArcSight CEF event
+func parseCEF(text string, _ time.Time) (map[string]interface{}, error) {
+	parsed, err := parsers.ParseCEF([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+	return toFields(parsed)
+}
+
+// parseLEEF parses a QRadar LEEF 1.0 or 2.0 event
+func parseLEEF(text string, _ time.Time) (map[string]interface{}, error) {
+	parsed, err := parsers.ParseLEEF([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+	return toFields(parsed)
+}
+
+// newKVParser parses key=value pairs with the stage's separators
+func newKVParser(cfg config.Stage) (textParser, error) {
+	return func(text string, _ time.Time) (map[string]interface{}, error) {
+		pairs, err := parsers.ParseKV([]byte(text), cfg.KV.FieldSplit, cfg.KV.ValueSplit)
+		if err != nil {
+			return nil, err
+		}
+		return stringFields(pairs), nil
+	}, nil
+}
+
+// newCSVParser parses one CSV record into the stage's columns
+func newCSVParser(cfg config.Stage) (textParser, error) {
+	if len(cfg.CSV.Columns) == 0 {
+		return nil, errors.New("csv columns are required")
+	}
+	delimiter := ','
+	if cfg.CSV.Delimiter != "" {
+		runes := []rune(cfg.CSV.Delimiter)
+		if len(runes) != 1 || runes[0] == '"' || runes[0] == '\r' || runes[0] == '\n' {
+			return nil, fmt.Errorf("invalid csv delimiter %q", cfg.CSV.Delimiter)
+		}
+		delimiter = runes[0]
+	}
+	columns := cfg.CSV.Columns
+
+	return func(text string, _ time.Time) (map[string]interface{}, error) {
+		values, err := parsers.ParseCSV([]byte(text), columns, delimiter)
+		if err != nil {
+			return nil, err
+		}
+		return stringFields(values), nil
+	}, nil
+}
+
+// stringFields converts parsed string pairs to fields; dotted names become nested objects
+func stringFields(pairs map[string]string) map[string]interface{} {
+	fields := make(map[string]interface{}, len(pairs))
+	for name, value := range pairs {
+		setField(fields, name, value)
+	}
+	return fields
+}
+
+// toFields converts a parsed struct to fields through its JSON encoding
+func toFields(value interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// renameStage moves fields to new names
+type renameStage struct {
+	renames []config.FieldRename
+}
+
+func newRenameStage(cfg config.Stage) (Processor, error) {
+	if len(cfg.Renames) == 0 {
+		return nil, errors.New("renames are required")
+	}
+	for _, rename := range cfg.Renames {
+		if rename.From == "" || rename.To == "" {
+			return nil, errors.New("renames need both from and to")
+		}
+	}
+	return &renameStage{renames: cfg.Renames}, nil
+}
+
+// Process moves each field that exists; missing fields are skipped
+func (s *renameStage) Process(event *Event) (bool, error) {
+	for _, rename := range s.renames {
+		value, ok := getField(event.Fields, rename.From)
+		if !ok {
+			continue
+		}
+		deleteField(event.Fields, rename.From)
+		setField(event.Fields, rename.To, value)
+	}
+	return true, nil
+}
+
+// fieldMatcher tests a field against a regexp
+type fieldMatcher struct {
+	field   string
+	pattern *regexp.Regexp
+}
+
+func newFieldMatcher(cfg config.Stage) (fieldMatcher, error) {
+	if cfg.Pattern == "" {
+		return fieldMatcher{}, errors.New("pattern is required")
+	}
+	pattern, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return fieldMatcher{}, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return fieldMatcher{field: fieldOrDefault(cfg.Field), pattern: pattern}, nil
+}
+
+// matches reports whether the field exists and matches
+func (m fieldMatcher) matches(event *Event) bool {
+	value, ok := getField(event.Fields, m.field)
+	return ok && m.pattern.MatchString(valueString(value))
+}
+
+// dropStage discards events whose field matches
+type dropStage struct {
+	fieldMatcher
+}
+
+func newDropStage(cfg config.Stage) (Processor, error) {
+	matcher, err := newFieldMatcher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &dropStage{fieldMatcher: matcher}, nil
+}
+
+// Process drops matching events
+func (s *dropStage) Process(event *Event) (bool, error) {
+	return !s.matches(event), nil
+}
+
+// addFieldsStage sets fixed field values
+type addFieldsStage struct {
+	values []config.FieldValue
+}
+
+func newAddFieldsStage(cfg config.Stage) (Processor, error) {
+	if len(cfg.Values) == 0 {
+		return nil, errors.New("values are required")
+	}
+	for _, value := range cfg.Values {
+		if value.Field == "" {
+			return nil, errors.New("values need a field")
+		}
+	}
+	return &addFieldsStage{values: cfg.Values}, nil
+}
+
+// Process sets each field, replacing existing values
+func (s *addFieldsStage) Process(event *Event) (bool, error) {
+	for _, value := range s.values {
+		setField(event.Fields, value.Field, value.Value)
+	}
+	return true, nil
+}
+
+// redactStage masks sensitive values
+type redactStage struct {
+	fields      []string
+	pattern     *regexp.Regexp // nil masks whole values
+	replacement string
+}
+
+func newRedactStage(cfg config.Stage) (Processor, error) {
+	if len(cfg.Fields) == 0 && cfg.Pattern == "" {
+		return nil, errors.New("fields or pattern is required")
+	}
+
+	s := &redactStage{fields: cfg.Fields, replacement: cfg.Replacement}
+	if s.replacement == "" {
+		s.replacement = defaultReplacement
+	}
+	if cfg.Pattern != "" {
+		pattern, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		s.pattern = pattern
+	}
+	return s, nil
+}
+
+// Process masks the listed fields, or every string in the record when none are listed
+func (s *redactStage) Process(event *Event) (bool, error) {
+	if len(s.fields) == 0 {
+		s.mask(event.Fields)
+		return true, nil
+	}
+	for _, field := range s.fields {
+		if value, ok := getField(event.Fields, field); ok {
+			setField(event.Fields, field, s.mask(value))
+		}
+	}
+	return true, nil
+}
+
+// mask returns the redacted value; objects and arrays are masked in place
+func (s *redactStage) mask(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if s.pattern == nil {
+			return s.replacement
+		}
+		return s.pattern.ReplaceAllString(v, s.replacement)
+	case map[string]interface{}:
+		for name, child := range v {
+			v[name] = s.mask(child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = s.mask(child)
+		}
+		return v
+	default:
+		if s.pattern == nil {
+			return s.replacement
+		}
+		return v
+	}
+}
+
+// routeStage sends matching events to another dataset or tenant
+type routeStage struct {
+	fieldMatcher
+	datasetID string
+	tenantID  string
+}
+
+func newRouteStage(cfg config.Stage) (Processor, error) {
+	if cfg.DatasetID == "" && cfg.TenantID == "" {
+		return nil, errors.New("dataset_id or tenant_id is required")
+	}
+	matcher, err := newFieldMatcher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &routeStage{fieldMatcher: matcher, datasetID: cfg.DatasetID, tenantID: cfg.TenantID}, nil
+}
+
+// Process re-targets matching events; later stages still run
+func (s *routeStage) Process(event *Event) (bool, error) {
+	if !s.matches(event) {
+		return true, nil
+	}
+	if s.datasetID != "" {
+		event.DatasetID = s.datasetID
+	}
+	if s.tenantID != "" {
+		event.TenantID = s.tenantID
+	}
+	return true, nil
+}
+
+// fieldOrDefault returns the configured field or the envelope message field
+func fieldOrDefault(field string) string {
+	if field == "" {
+		return defaultField
+	}
+	return field
+}
@@ -0,0 +1,218 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+)
+
+// Timestamp stage defaults and output fields
+const (
+	defaultTimestampField  = "timestamp"
+	defaultTimestampTarget = "@timestamp"
+	receivedAtField        = "received_at"
+	clockSkewField         = "clock_skew_seconds"
+	timestampErrorField    = "timestamp_error"
+)
+
+// Epoch layouts; "unix" infers the unit from the size of the value
+const (
+	layoutUnix   = "unix"
+	layoutUnixS  = "unix_s"
+	layoutUnixMS = "unix_ms"
+	layoutUnixUS = "unix_us"
+	layoutUnixNS = "unix_ns"
+)
+
+// namedLayouts maps layout names to Go layouts; other layouts are used as given
+var namedLayouts = map[string]string{
+	"rfc3339":    time.RFC3339Nano, // Also accepts times without fractional seconds
+	"rfc1123":    time.RFC1123,
+	"rfc1123z":   time.RFC1123Z,
+	"rfc822":     time.RFC822,
+	"rfc822z":    time.RFC822Z,
+	"rfc850":     time.RFC850,
+	"ansic":      time.ANSIC,
+	"unixdate":   time.UnixDate,
+	"syslog":     time.Stamp,
+	"stampmilli": time.StampMilli,
+	"stampmicro": time.StampMicro,
+	"httpdate":   "02/Jan/2006:15:04:05 -0700",
+}
+
+// Epochs outside the years RFC 3339 can represent (0000-01-01 to 9999-12-31) are rejected
+const (
+	minEpochSeconds = -62167219200
+	maxEpochSeconds = 253402300799
+)
+
+// defaultLayouts are tried when a timestamp stage lists none
+var defaultLayouts = []string{"rfc3339", layoutUnix}
+
+// timestampStage sets a normalized event time from a field, keeping the receive time
+type timestampStage struct {
+	field    string
+	target   string
+	layouts  []string
+	location *time.Location
+	maxSkew  time.Duration
+}
+
+func newTimestampStage(cfg config.Stage) (Processor, error) {
+	s := &timestampStage{
+		field:    cfg.Field,
+		target:   cfg.Target,
+		location: time.UTC,
+		maxSkew:  time.Duration(cfg.MaxSkewSeconds) * time.Second,
+	}
+	if s.field == "" {
+		s.field = defaultTimestampField
+	}
+	if s.target == "" {
+		s.target = defaultTimestampTarget
+	}
+	if cfg.Timezone != "" {
+		location, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone: %w", err)
+		}
+		s.location = location
+	}
+
+	layouts := cfg.Layouts
+	if len(layouts) == 0 {
+		layouts = defaultLayouts
+	}
+	for _, layout := range layouts {
+		if named, ok := namedLayouts[strings.ToLower(layout)]; ok {
+			layout = named
+		}
+		s.layouts = append(s.layouts, layout)
+	}
+	return s, nil
+}
+
+// Process writes the event time to the target and the receive time to received_at.
+// Events without a usable time get the receive time and, when the field could not
+// be parsed, a timestamp_error.
+func (s *timestampStage) Process(event *Event) (bool, error) {
+	received := event.Timestamp.UTC()
+	event.Fields[receivedAtField] = received.Format(time.RFC3339Nano)
+
+	value, ok := getField(event.Fields, s.field)
+	if !ok {
+		event.Fields[s.target] = received.Format(time.RFC3339Nano)
+		return true, nil
+	}
+
+	eventTime, err := s.parse(value, received)
+	if err != nil {
+		event.Fields[s.target] = received.Format(time.RFC3339Nano)
+		event.Fields[timestampErrorField] = err.Error()
+		return true, err
+	}
+
+	event.Fields[s.target] = eventTime.UTC().Format(time.RFC3339Nano)
+	if skew := eventTime.Sub(received); s.maxSkew > 0 && skew.Abs() > s.maxSkew {
+		event.Fields[clockSkewField] = math.Round(skew.Seconds()*1000) / 1000
+	}
+	return true, nil
+}
+
+// parse tries each layout in order on a string or numeric value
+func (s *timestampStage) parse(value interface{}, received time.Time) (time.Time, error) {
+	text := strings.TrimSpace(valueString(value))
+	if text == "" {
+		return time.Time{}, errors.New("empty timestamp")
+	}
+
+	for _, layout := range s.layouts {
+		switch layout {
+		case layoutUnix, layoutUnixS, layoutUnixMS, layoutUnixUS, layoutUnixNS:
+			if t, ok := parseEpoch(text, layout); ok {
+				return t, nil
+			}
+			continue
+		}
+
+		t, err := time.ParseInLocation(layout, text, s.location)
+		if err != nil {
+			continue
+		}
+		if t.Year() == 0 {
+			t = completeYear(t, received.In(s.location))
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q matches no timestamp layout", text)
+}
+
+// parseEpoch parses an epoch number, which may have a fractional part. Values that
+// are not finite or fall outside years 0000-9999 are rejected.
+func parseEpoch(text, layout string) (time.Time, bool) {
+	number := json.Number(text)
+	seconds, err := number.Float64()
+	if err != nil || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+		return time.Time{}, false
+	}
+
+	unit := layout
+	if unit == layoutUnix {
+		// Infer the unit from the magnitude: seconds reach 1e10 in the year 2286
+		switch magnitude := math.Abs(seconds); {
+		case magnitude < 1e11:
+			unit = layoutUnixS
+		case magnitude < 1e14:
+			unit = layoutUnixMS
+		case magnitude < 1e17:
+			unit = layoutUnixUS
+		default:
+			unit = layoutUnixNS
+		}
+	}
+
+	switch unit {
+	case layoutUnixMS:
+		seconds /= 1e3
+	case layoutUnixUS:
+		seconds /= 1e6
+	case layoutUnixNS:
+		seconds /= 1e9
+	}
+	if seconds < minEpochSeconds || seconds > maxEpochSeconds {
+		return time.Time{}, false
+	}
+
+	// Integers are converted exactly; float64 cannot hold nanosecond epochs
+	if integer, err := strconv.ParseInt(text, 10, 64); err == nil {
+		switch unit {
+		case layoutUnixS:
+			return time.Unix(integer, 0), true
+		case layoutUnixMS:
+			return time.UnixMilli(integer), true
+		case layoutUnixUS:
+			return time.UnixMicro(integer), true
+		default:
+			return time.Unix(0, integer), true
+		}
+	}
+
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*1e9)), true
+}
+
+// completeYear adds the receive year to layouts without one, such as syslog's
+// "Jan _2 15:04:05". Times more than a day ahead are taken from the previous year.
+func completeYear(t, received time.Time) time.Time {
+	t = t.AddDate(received.Year(), 0, 0)
+	if t.After(received.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
@@ -1,15 +1,37 @@
 package services
 
 import (
+	"errors"
+
 	"github.com/n0needt0/bytefreezer-proxy/config"
 	"github.com/n0needt0/bytefreezer-proxy/domain"
 )
 
+var (
+	// ErrIngestQueueFull is returned when the batching pipeline cannot accept more messages
+	ErrIngestQueueFull = errors.New("ingest queue full")
+	// ErrIngestUnavailable is returned when the batching pipeline is not running
+	ErrIngestUnavailable = errors.New("ingest pipeline unavailable")
+)
+
+// Ingestor accepts messages into the batching pipeline. Ingest queues every message
+// or none, except when the pipeline stops part way; it returns the number queued.
+type Ingestor interface {
+	Ingest(msgs []*domain.UDPMessage) (int, error)
+}
+
+// ListenerReporter reports the runtime state of the configured listeners
+type ListenerReporter interface {
+	ListenerStatuses() []domain.ListenerStatus
+}
+
 // Services holds all service instances and shared state
 type Services struct {
 	Config          *config.Config
 	ProxyStats      *domain.ProxyStats
 	SpoolingService *SpoolingService
+	Ingestor        Ingestor         // Set when the UDP listener (and its forwarder) is enabled
+	Listeners       ListenerReporter // Set when the UDP listener is enabled
 
 	// Service instances will be added here
 	// UDPListener  *udp.Listener
@@ -0,0 +1,104 @@
+//go:build linux
+
+package udp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/n0needt0/go-goodies/log"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// batchReader reads several datagrams per system call (recvmmsg)
+type batchReader interface {
+	ReadBatch(messages []ipv4.Message, flags int) (int, error)
+}
+
+// startBatchReaders opens reuseport_readers SO_REUSEPORT sockets on the listener's
+// port. The kernel spreads senders across the sockets and each one is read in
+// batches by its own goroutine.
+func (l *Listener) startBatchReaders(portListener *UDPPortListener) error {
+	reusePortConfig := listenConfig(portListener.bindInterface, true)
+
+	for i := 0; i < l.config.UDP.ReusePortReaders; i++ {
+		packetConn, err := reusePortConfig.ListenPacket(context.Background(), "udp", portListener.addr.String())
+		if err != nil {
+			return fmt.Errorf("failed to listen on UDP %s: %w", portListener.addr.String(), err)
+		}
+		conn := packetConn.(*net.UDPConn)
+		portListener.batchConns = append(portListener.batchConns, conn)
+
+		if err := conn.SetReadBuffer(l.config.UDP.ReadBufferSizeBytes); err != nil {
+			return fmt.Errorf("failed to set read buffer for %s: %w", portListener.addr.String(), err)
+		}
+	}
+
+	log.Info("UDP server listening on " + portListener.batchConns[0].LocalAddr().String() + " with " +
+		fmt.Sprintf("%d", len(portListener.batchConns)) + " batch readers (tenant: " + portListener.tenantID +
+		", dataset: " + portListener.datasetID + ")")
+
+	for _, conn := range portListener.batchConns {
+		l.wg.Add(1)
+		go func(conn *net.UDPConn) {
+			defer l.wg.Done()
+			l.handleBatchesForPort(portListener, conn)
+		}(conn)
+	}
+	return nil
+}
+
+// handleBatchesForPort reads datagrams from one SO_REUSEPORT socket with recvmmsg.
+// Each reader owns its buffers, so no pooled buffers are taken per datagram.
+func (l *Listener) handleBatchesForPort(portListener *UDPPortListener, conn *net.UDPConn) {
+	defer l.flushMultiline(portListener)
+
+	var reader batchReader = ipv4.NewPacketConn(conn)
+	if portListener.addr.IP != nil && portListener.addr.IP.To4() == nil {
+		reader = ipv6.NewPacketConn(conn)
+	}
+
+	// One spare byte per buffer reveals datagrams longer than max_datagram_bytes
+	messages := make([]ipv4.Message, l.config.UDP.ReadBatchSize)
+	for i := range messages {
+		messages[i].Buffers = [][]byte{make([]byte, l.config.UDP.MaxDatagramBytes+1)}
+	}
+
+	for {
+		select {
+		case <-l.quit:
+			return
+		default:
+		}
+
+		conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+		count, err := reader.ReadBatch(messages, 0)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				l.expireListenerState(portListener)
+				continue
+			}
+
+			if l.isClosedConnError(err) {
+				return
+			}
+
+			log.Errorf("UDP batch read error on port %d: %v", portListener.port, err)
+			l.services.ProxyStats.UDPMessageErrors++
+
+			if l.config.SOCAlertClient != nil {
+				l.config.SOCAlertClient.SendUDPListenerFailureAlert(err)
+			}
+			continue
+		}
+
+		for i := 0; i < count; i++ {
+			message := &messages[i]
+			l.dispatchDatagram(portListener, l.datagramPayload(message.Buffers[0], message.N, message.Addr), message.Addr)
+		}
+	}
+}
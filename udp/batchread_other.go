@@ -0,0 +1,9 @@
+//go:build !linux
+
+package udp
+
+// startBatchReaders is only available on Linux; other platforms use the
+// single reader of handleMessagesForPort
+func (l *Listener) startBatchReaders(portListener *UDPPortListener) error {
+	return errBatchReadUnsupported
+}
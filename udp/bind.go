@@ -0,0 +1,30 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+)
+
+// bindIP returns the address a listener binds to: its own host, or udp.host.
+// A nil IP (empty host) binds all interfaces.
+func bindIP(cfg *config.Config, udpListener config.UDPListener) (net.IP, error) {
+	if udpListener.Host == "" {
+		return net.ParseIP(cfg.UDP.Host), nil
+	}
+	ip := net.ParseIP(udpListener.Host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid host %q", udpListener.Host)
+	}
+	return ip, nil
+}
+
+// listenConfig returns the socket options for a listener socket: SO_BINDTODEVICE
+// when an interface is configured, and SO_REUSEPORT for batch readers
+func listenConfig(bindInterface string, reusePort bool) *net.ListenConfig {
+	if bindInterface == "" && !reusePort {
+		return &net.ListenConfig{}
+	}
+	return &net.ListenConfig{Control: socketControl(bindInterface, reusePort)}
+}
@@ -0,0 +1,109 @@
+package udp
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/decoders"
+	"github.com/n0needt0/go-goodies/log"
+)
+
+// newDecoder returns the datagram decoder for a listener format, or nil for line formats
+func newDecoder(cfg *config.Config, udpListener config.UDPListener, format string) decoders.Decoder {
+	switch format {
+	case FormatGELF:
+		return decoders.NewGELFDecoder(
+			time.Duration(udpListener.GELFChunkTimeoutSeconds)*time.Second, cfg.UDP.MaxFrameBytes)
+	case FormatNetFlow:
+		return decoders.NewNetFlowDecoder()
+	case FormatSFlow:
+		return decoders.NewSFlowDecoder()
+	case FormatSNMP:
+		return newSNMPTrapDecoder(udpListener)
+	}
+	return nil
+}
+
+// newSNMPTrapDecoder builds an SNMP trap decoder, skipping invalid users and
+// falling back to numeric OIDs when the MIB file cannot be loaded
+func newSNMPTrapDecoder(udpListener config.UDPListener) decoders.Decoder {
+	var users []decoders.SNMPUser
+	for _, u := range udpListener.SNMP.Users {
+		user := decoders.SNMPUser{
+			Username:       u.Username,
+			AuthProtocol:   u.AuthProtocol,
+			AuthPassphrase: u.AuthPassphrase,
+			PrivProtocol:   u.PrivProtocol,
+			PrivPassphrase: u.PrivPassphrase,
+		}
+		if err := user.Validate(); err != nil {
+			log.Errorf("Ignoring SNMP user on port %d: %v", udpListener.Port, err)
+			continue
+		}
+		users = append(users, user)
+	}
+
+	var mibNames *decoders.MIBNames
+	if udpListener.SNMP.MIBFile != "" {
+		var err error
+		mibNames, err = decoders.LoadMIBNames(udpListener.SNMP.MIBFile)
+		if err != nil {
+			log.Errorf("Failed to load MIB names for port %d: %v", udpListener.Port, err)
+		}
+	}
+
+	return decoders.NewSNMPTrapDecoder(udpListener.SNMP.Communities, users, mibNames)
+}
+
+// handleDatagram decodes a binary datagram into records before batching
+func (l *Listener) handleDatagram(portListener *UDPPortListener, data []byte, from net.Addr) {
+	records, err := portListener.decoder.Decode(data, from, time.Now())
+	if err != nil {
+		l.countDecodeError(err)
+		log.Debugf("Failed to decode %s datagram from %s on port %d: %v",
+			portListener.format, from, portListener.port, err)
+	}
+
+	// Decoders may return the records they could decode alongside an error
+	for _, record := range records {
+		l.processMessageWithContext(record, from, portListener.tenantID, portListener.datasetID, portListener.format, portListener.jsonMode, portListener.pipeline)
+	}
+
+	l.expireDecoderState(portListener)
+}
+
+// countDecodeError records a decode failure in the matching statistic
+func (l *Listener) countDecodeError(err error) {
+	switch {
+	case errors.Is(err, decoders.ErrGELFChunk):
+		l.services.ProxyStats.GELFChunkErrors++
+	case errors.Is(err, decoders.ErrNetFlowTemplateMissing):
+		l.services.ProxyStats.NetFlowTemplateMisses++
+	case errors.Is(err, decoders.ErrSNMPAuth):
+		l.services.ProxyStats.SNMPAuthFailures++
+	case errors.Is(err, decoders.ErrSNMPInformUnacknowledged):
+		l.services.ProxyStats.SNMPInformsUnacked++
+	default:
+		l.services.ProxyStats.ParseErrors++
+	}
+}
+
+// expireDecoderState ages out stale decoder state such as incomplete GELF chunk sets
+func (l *Listener) expireDecoderState(portListener *UDPPortListener) {
+	expirer, ok := portListener.decoder.(decoders.Expirer)
+	if !ok {
+		return
+	}
+
+	if expired := expirer.Expire(time.Now()); expired > 0 {
+		switch portListener.format {
+		case FormatGELF:
+			l.services.ProxyStats.GELFChunkSetsExpired += int64(expired)
+		case FormatNetFlow:
+			l.services.ProxyStats.NetFlowTemplatesEvicted += int64(expired)
+		}
+		log.Debugf("Expired %d stale %s decoder entries on port %d", expired, portListener.format, portListener.port)
+	}
+}
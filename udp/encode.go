@@ -0,0 +1,162 @@
+package udp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/domain"
+	"github.com/n0needt0/bytefreezer-proxy/parsers"
+	"github.com/n0needt0/go-goodies/log"
+)
+
+// Listener payload formats
+const (
+	FormatRaw     = "raw"
+	FormatSyslog  = "syslog"
+	FormatCEF     = "cef"
+	FormatLEEF    = "leef"
+	FormatKV      = "kv"
+	FormatCSV     = "csv"
+	FormatGELF    = "gelf"
+	FormatNetFlow = "netflow"
+	FormatSFlow   = "sflow"
+	FormatSNMP    = "snmp"
+)
+
+// JSON modes control how payloads that are already JSON are written
+const (
+	JSONModeNormalize    = "normalize"     // Decode and re-encode with sorted keys (default)
+	JSONModeCompact      = "compact"       // Remove insignificant whitespace, keep keys and numbers as sent
+	JSONModeValidateOnly = "validate_only" // Check validity and pass the original bytes through
+)
+
+// formatOf returns a listener's format, falling back to raw for unknown values
+func formatOf(udpListener config.UDPListener) string {
+	format := strings.ToLower(udpListener.Format)
+	switch format {
+	case FormatRaw, FormatSyslog, FormatCEF, FormatLEEF, FormatKV, FormatCSV,
+		FormatGELF, FormatNetFlow, FormatSFlow, FormatSNMP:
+		return format
+	case "":
+		return FormatRaw
+	}
+	log.Warnf("Unknown format %q for dataset %s, using %s", udpListener.Format, udpListener.DatasetID, FormatRaw)
+	return FormatRaw
+}
+
+// jsonModeOf returns a listener's json_mode, falling back to normalize for unknown values
+func jsonModeOf(udpListener config.UDPListener) string {
+	mode := strings.ToLower(udpListener.JSONMode)
+	switch mode {
+	case JSONModeNormalize, JSONModeCompact, JSONModeValidateOnly:
+		return mode
+	case "":
+		return JSONModeNormalize
+	}
+	log.Warnf("Unknown json_mode %q for dataset %s, using %s", udpListener.JSONMode, udpListener.DatasetID, JSONModeNormalize)
+	return JSONModeNormalize
+}
+
+// syslogRecord is a parsed syslog message plus receive metadata
+type syslogRecord struct {
+	*parsers.SyslogMessage
+	Source     string `json:"source"`
+	ReceivedAt string `json:"received_at"`
+}
+
+// encodeJSON writes a JSON payload as one NDJSON line according to the message's
+// json_mode. It returns false, writing nothing, when the payload is not valid JSON.
+func encodeJSON(ndjsonData *bytes.Buffer, msg *domain.UDPMessage) bool {
+	switch msg.JSONMode {
+	case JSONModeValidateOnly:
+		if !json.Valid(msg.Data) {
+			return false
+		}
+		// NDJSON needs one record per line, so only multi-line documents are compacted
+		if bytes.ContainsAny(msg.Data, "\r\n") {
+			if err := json.Compact(ndjsonData, msg.Data); err != nil {
+				return false
+			}
+		} else {
+			ndjsonData.Write(msg.Data)
+		}
+
+	case JSONModeCompact:
+		if err := json.Compact(ndjsonData, msg.Data); err != nil {
+			return false
+		}
+
+	default:
+		// Numbers stay json.Number so 64-bit integers survive the round trip
+		decoder := json.NewDecoder(bytes.NewReader(msg.Data))
+		decoder.UseNumber()
+		var jsonObj interface{}
+		if err := decoder.Decode(&jsonObj); err != nil {
+			return false
+		}
+		if _, err := decoder.Token(); err != io.EOF {
+			return false // Trailing data after the first value
+		}
+
+		// Valid JSON, marshal it to ensure consistent formatting
+		if jsonBytes, err := json.Marshal(jsonObj); err == nil {
+			ndjsonData.Write(jsonBytes)
+		} else {
+			// Fallback to raw data
+			ndjsonData.Write(msg.Data)
+		}
+	}
+
+	ndjsonData.WriteByte('\n')
+	return true
+}
+
+// encodeMessage writes a single message as one NDJSON line
+func (f *Forwarder) encodeMessage(ndjsonData *bytes.Buffer, msg *domain.UDPMessage) {
+	parseError := ""
+
+	switch msg.Format {
+	case FormatSyslog:
+		parsed, err := parsers.ParseSyslog(msg.Data, msg.Timestamp)
+		if err == nil {
+			var jsonBytes []byte
+			jsonBytes, err = json.Marshal(syslogRecord{
+				SyslogMessage: parsed,
+				Source:        msg.From,
+				ReceivedAt:    msg.Timestamp.Format(time.RFC3339Nano),
+			})
+			if err == nil {
+				ndjsonData.Write(jsonBytes)
+				ndjsonData.WriteByte('\n')
+				return
+			}
+		}
+		log.Debugf("Failed to parse syslog message from %s: %v", msg.From, err)
+		f.services.ProxyStats.ParseErrors++
+		parseError = "syslog: " + err.Error()
+
+	default:
+		// Try to parse as JSON first
+		if encodeJSON(ndjsonData, msg) {
+			return
+		}
+	}
+
+	// Not valid JSON (or failed to parse), create a JSON envelope
+	envelope := map[string]interface{}{
+		"message":   string(msg.Data),
+		"source":    msg.From,
+		"timestamp": msg.Timestamp.Format(time.RFC3339Nano),
+	}
+	if parseError != "" {
+		envelope["parse_error"] = parseError
+	}
+	if jsonBytes, err := json.Marshal(envelope); err == nil {
+		ndjsonData.Write(jsonBytes)
+		ndjsonData.WriteByte('\n')
+	}
+}
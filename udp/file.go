@@ -0,0 +1,445 @@
+package udp
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/domain"
+	"github.com/n0needt0/bytefreezer-proxy/pipeline"
+	"github.com/n0needt0/go-goodies/log"
+)
+
+// ProtocolFile is the listener protocol for tailing local files
+const ProtocolFile = "file"
+
+// filePollInterval is how often globs are re-evaluated and files are read
+const filePollInterval = time.Second
+
+// fileReadChunk is the size of each read from a tailed file
+const fileReadChunk = 64 * 1024
+
+// fileID identifies a file independently of its path
+type fileID struct {
+	Device uint64 `json:"device"`
+	Inode  uint64 `json:"inode"`
+}
+
+// pathIdentity derives a file identity from its path where inodes are unavailable
+func pathIdentity(path string) fileID {
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	return fileID{Inode: h.Sum64()}
+}
+
+// fileCheckpoint is one persisted read offset
+type fileCheckpoint struct {
+	fileID
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+}
+
+// FileInput tails the files matching a set of glob patterns
+type FileInput struct {
+	index     int // Position in udp.listeners
+	patterns  []string
+	tenantID  string
+	datasetID string
+	format    string
+	jsonMode  string
+	pipeline  *pipeline.Pipeline
+}
+
+// newFileInput creates a file input from its configuration entry
+func newFileInput(udpListener config.UDPListener, tenantID string) *FileInput {
+	return &FileInput{
+		patterns:  udpListener.Paths,
+		tenantID:  tenantID,
+		datasetID: udpListener.DatasetID,
+		format:    formatOf(udpListener),
+		jsonMode:  jsonModeOf(udpListener),
+	}
+}
+
+// tailedFile is an open file being followed
+type tailedFile struct {
+	id         fileID
+	path       string
+	input      *FileInput
+	file       *os.File
+	offset     int64        // Bytes read from the file so far
+	partial    []byte       // Unterminated last line
+	discarding bool         // Skipping the rest of an oversized line
+	lines      *lineTracker // Offsets of lines waiting for delivery
+}
+
+// pendingLine is a queued line waiting for its batch to be forwarded or spooled
+type pendingLine struct {
+	end        int64 // File offset just past the line
+	generation int
+	delivered  bool
+}
+
+// lineTracker computes the offset up to which every line of a file has been
+// delivered. Batches complete out of order, and pipeline drops complete at once,
+// so the committed offset only advances over a contiguous delivered prefix.
+type lineTracker struct {
+	mu         sync.Mutex
+	committed  int64
+	pending    *list.List // Undelivered lines in file order
+	generation int        // Bumped on reset so callbacks of earlier lines are ignored
+	failed     bool       // A batch was lost; committed stays put until a restart re-reads it
+}
+
+func newLineTracker(offset int64) *lineTracker {
+	return &lineTracker{committed: offset, pending: list.New()}
+}
+
+// track registers a queued line. It returns nil once a line has failed, since
+// nothing after it can be committed.
+func (lt *lineTracker) track(end int64) *pendingLine {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if lt.failed {
+		return nil
+	}
+	line := &pendingLine{end: end, generation: lt.generation}
+	lt.pending.PushBack(line)
+	return line
+}
+
+// skip accounts for a line that is not queued, such as a blank or oversized line
+func (lt *lineTracker) skip(end int64) {
+	if line := lt.track(end); line != nil {
+		lt.deliver(line)
+	}
+}
+
+// deliver marks a line delivered and advances over the delivered prefix
+func (lt *lineTracker) deliver(line *pendingLine) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if line == nil || line.generation != lt.generation || lt.failed {
+		return
+	}
+	line.delivered = true
+	for element := lt.pending.Front(); element != nil; element = lt.pending.Front() {
+		front := element.Value.(*pendingLine)
+		if !front.delivered {
+			break
+		}
+		lt.committed = front.end
+		lt.pending.Remove(element)
+	}
+}
+
+// fail stops the committed offset before a line whose batch was lost. It returns
+// true for the first failure.
+func (lt *lineTracker) fail(line *pendingLine) bool {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if line == nil || line.generation != lt.generation || lt.failed {
+		return false
+	}
+	lt.failed = true
+	lt.pending.Init()
+	return true
+}
+
+// reset restarts tracking at an offset, e.g. after truncation
+func (lt *lineTracker) reset(offset int64) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	lt.generation++
+	lt.committed = offset
+	lt.pending = list.New()
+	lt.failed = false
+}
+
+// offset returns the end of the delivered prefix
+func (lt *lineTracker) offset() int64 {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	return lt.committed
+}
+
+// fileTailer follows the files of all file inputs and checkpoints how far each has
+// been forwarded or spooled. After a crash, lines that were read but not yet delivered
+// are read again, so delivery is at least once.
+type fileTailer struct {
+	listener       *Listener
+	inputs         []*FileInput
+	checkpointPath string
+	checkpoints    map[fileID]int64 // Offsets loaded at startup
+	files          map[fileID]*tailedFile
+	lastSaved      string
+}
+
+// newFileTailer creates the tailer; offsets are stored next to the spooling directory
+func newFileTailer(l *Listener, inputs []*FileInput) *fileTailer {
+	return &fileTailer{
+		listener:       l,
+		inputs:         inputs,
+		checkpointPath: filepath.Clean(l.config.Spooling.Directory) + "-file-offsets.json",
+		checkpoints:    make(map[fileID]int64),
+		files:          make(map[fileID]*tailedFile),
+	}
+}
+
+// run polls the configured globs until the listener shuts down
+func (t *fileTailer) run() {
+	t.loadCheckpoints()
+	log.Info(fmt.Sprintf("File input tailing %d pattern set(s), offsets stored in %s", len(t.inputs), t.checkpointPath))
+
+	ticker := time.NewTicker(filePollInterval)
+	defer ticker.Stop()
+
+	for {
+		if !t.poll() {
+			return
+		}
+		t.saveCheckpoints()
+
+		select {
+		case <-t.listener.quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll opens newly matched files, reads new lines from every open file and closes
+// files that no longer match (rotated away or deleted) once they are drained.
+// It returns false on shutdown.
+func (t *fileTailer) poll() bool {
+	seen := make(map[fileID]bool)
+
+	for _, input := range t.inputs {
+		for _, pattern := range input.patterns {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				log.Errorf("Invalid file input pattern %q: %v", pattern, err)
+				continue
+			}
+
+			for _, path := range matches {
+				info, err := os.Stat(path)
+				if err != nil || !info.Mode().IsRegular() {
+					continue
+				}
+
+				id := fileIdentity(path, info)
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+
+				if tf, ok := t.files[id]; ok {
+					tf.path = path
+					continue
+				}
+				t.open(path, id, input)
+			}
+		}
+	}
+
+	for id, tf := range t.files {
+		if !t.read(tf) {
+			return false
+		}
+		if !seen[id] {
+			log.Debugf("Stopped tailing %s (rotated or removed)", tf.path)
+			tf.file.Close()
+			delete(t.files, id)
+		}
+	}
+	return true
+}
+
+// open starts following a file from its checkpoint, or from the beginning
+func (t *fileTailer) open(path string, id fileID, input *FileInput) {
+	file, err := os.Open(path) // #nosec G304 - path comes from configured glob patterns
+	if err != nil {
+		log.Warnf("Failed to open %s: %v", path, err)
+		return
+	}
+
+	offset := t.checkpoints[id]
+	if info, err := file.Stat(); err == nil && info.Size() < offset {
+		offset = 0 // Truncated (or a reused inode) since the checkpoint was written
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		log.Warnf("Failed to seek %s to %d: %v", path, offset, err)
+		file.Close()
+		return
+	}
+
+	tf := &tailedFile{id: id, path: path, input: input, file: file, offset: offset, lines: newLineTracker(offset)}
+	t.files[id] = tf
+	log.Info(fmt.Sprintf("Tailing %s from offset %d (tenant: %s, dataset: %s)", path, offset, input.tenantID, input.datasetID))
+}
+
+// read enqueues all complete lines appended since the last read.
+// It returns false on shutdown.
+func (t *fileTailer) read(tf *tailedFile) bool {
+	if info, err := tf.file.Stat(); err == nil && info.Size() < tf.offset {
+		log.Info(tf.path + " was truncated, reading from the beginning")
+		if _, err := tf.file.Seek(0, io.SeekStart); err != nil {
+			log.Warnf("Failed to rewind %s: %v", tf.path, err)
+			return true
+		}
+		tf.offset = 0
+		tf.partial = nil
+		tf.discarding = false
+		tf.lines.reset(0)
+	}
+
+	maxBytes := t.listener.config.UDP.MaxFrameBytes
+	buf := make([]byte, fileReadChunk)
+	for {
+		n, err := tf.file.Read(buf)
+		if n > 0 {
+			chunk := buf[:n]
+			pos := tf.offset // File offset of chunk[0]
+			tf.offset += int64(n)
+
+			for len(chunk) > 0 {
+				i := bytes.IndexByte(chunk, '\n')
+				if i < 0 {
+					if !tf.discarding {
+						tf.partial = append(tf.partial, chunk...)
+					}
+					if maxBytes > 0 && len(tf.partial) > maxBytes {
+						log.Warnf("Dropping oversized line in %s", tf.path)
+						t.listener.services.ProxyStats.UDPMessageErrors++
+						tf.partial = nil
+						tf.discarding = true
+					}
+					break
+				}
+
+				lineEnd := pos + int64(i) + 1
+				if !tf.discarding && maxBytes > 0 && len(tf.partial)+i > maxBytes {
+					log.Warnf("Dropping oversized line in %s", tf.path)
+					t.listener.services.ProxyStats.UDPMessageErrors++
+					tf.discarding = true
+				}
+				if tf.discarding {
+					tf.lines.skip(lineEnd)
+				} else if !t.enqueue(tf, append(tf.partial, chunk[:i]...), lineEnd) {
+					return false
+				}
+				tf.partial = nil
+				tf.discarding = false
+				chunk = chunk[i+1:]
+				pos = lineEnd
+			}
+		}
+
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Warnf("Failed to read %s: %v", tf.path, err)
+				t.listener.services.ProxyStats.UDPMessageErrors++
+			}
+			return true
+		}
+	}
+}
+
+// enqueue queues one line; its offset is committed once its batch has been forwarded
+// or spooled
+func (t *fileTailer) enqueue(tf *tailedFile, line []byte, lineEnd int64) bool {
+	payload := bytes.TrimRight(line, "\r")
+	if len(bytes.TrimSpace(payload)) == 0 {
+		tf.lines.skip(lineEnd)
+		return true
+	}
+
+	pending := tf.lines.track(lineEnd)
+
+	msg := &domain.UDPMessage{
+		Data:        bytes.Clone(payload),
+		From:        tf.path,
+		Timestamp:   time.Now(),
+		TenantID:    tf.input.tenantID,
+		DatasetID:   tf.input.datasetID,
+		Format:      tf.input.format,
+		JSONMode:    tf.input.jsonMode,
+		OnDelivered: func() { tf.lines.deliver(pending) },
+		OnFailed: func() {
+			if tf.lines.fail(pending) {
+				log.Warnf("A batch with lines of %s was lost; offsets are held at %d until a restart reads them again",
+					tf.path, tf.lines.offset())
+			}
+		},
+	}
+	return t.listener.enqueueBlocking(msg, tf.input.pipeline)
+}
+
+// loadCheckpoints reads the persisted offsets, if any
+func (t *fileTailer) loadCheckpoints() {
+	data, err := os.ReadFile(t.checkpointPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Failed to read file offsets %s: %v", t.checkpointPath, err)
+		}
+		return
+	}
+
+	var checkpoints []fileCheckpoint
+	if err := json.Unmarshal(data, &checkpoints); err != nil {
+		log.Warnf("Ignoring invalid file offsets %s: %v", t.checkpointPath, err)
+		return
+	}
+	for _, checkpoint := range checkpoints {
+		t.checkpoints[checkpoint.fileID] = checkpoint.Offset
+	}
+	t.lastSaved = string(data)
+}
+
+// saveCheckpoints atomically writes the committed offsets of all open files
+func (t *fileTailer) saveCheckpoints() {
+	checkpoints := make([]fileCheckpoint, 0, len(t.files))
+	for _, tf := range t.files {
+		checkpoints = append(checkpoints, fileCheckpoint{fileID: tf.id, Path: tf.path, Offset: tf.lines.offset()})
+	}
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].Path < checkpoints[j].Path })
+
+	data, err := json.Marshal(checkpoints)
+	if err != nil || string(data) == t.lastSaved {
+		return
+	}
+
+	tmpPath := t.checkpointPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		log.Warnf("Failed to write file offsets %s: %v", tmpPath, err)
+		return
+	}
+	if err := os.Rename(tmpPath, t.checkpointPath); err != nil {
+		log.Warnf("Failed to replace file offsets %s: %v", t.checkpointPath, err)
+		return
+	}
+	t.lastSaved = string(data)
+}
+
+// close closes all open files
+func (t *fileTailer) close() {
+	for id, tf := range t.files {
+		tf.file.Close()
+		delete(t.files, id)
+	}
+}
@@ -0,0 +1,150 @@
+package udp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/domain"
+	"github.com/n0needt0/bytefreezer-proxy/pipeline"
+	"github.com/n0needt0/bytefreezer-proxy/services"
+)
+
+// newTestListener creates a listener without sockets whose messages queue into a
+// buffered channel
+func newTestListener(t *testing.T) (*Listener, chan *domain.UDPMessage) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Spooling.Directory = filepath.Join(t.TempDir(), "spool")
+	cfg.UDP.MaxFrameBytes = 65536
+
+	batchChannel := make(chan *domain.UDPMessage, 16)
+	return &Listener{
+		services:     &services.Services{ProxyStats: &domain.ProxyStats{}},
+		config:       cfg,
+		quit:         make(chan struct{}),
+		batchChannel: batchChannel,
+		forwarder:    NewForwarder(nil, cfg),
+	}, batchChannel
+}
+
+// newTestTailer creates a file tailer for one input running the given stages
+func newTestTailer(t *testing.T, stages []config.Stage) (*fileTailer, *FileInput, chan *domain.UDPMessage) {
+	t.Helper()
+	pipe, err := pipeline.New(stages)
+	if err != nil {
+		t.Fatalf("pipeline.New() error = %v", err)
+	}
+
+	l, batchChannel := newTestListener(t)
+	input := &FileInput{datasetID: "file", format: FormatRaw, jsonMode: JSONModeNormalize, pipeline: pipe}
+	return newFileTailer(l, []*FileInput{input}), input, batchChannel
+}
+
+func TestFileOffsetsOutOfOrderDelivery(t *testing.T) {
+	tailer, input, batchChannel := newTestTailer(t, []config.Stage{
+		{Type: pipeline.StageDrop, Pattern: `^drop`},
+	})
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("first\ndrop me\n\nlast\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	id := pathIdentity(path)
+	tailer.open(path, id, input)
+	tf := tailer.files[id]
+	defer tf.file.Close()
+
+	if !tailer.read(tf) {
+		t.Fatal("read() = false, want true")
+	}
+	if len(batchChannel) != 2 {
+		t.Fatalf("queued %d lines, want 2", len(batchChannel))
+	}
+	first, last := <-batchChannel, <-batchChannel
+
+	// The dropped and blank lines are done, but the first line is still in a batch
+	settleBatch(&domain.DataBatch{Messages: []domain.UDPMessage{*last}}, true)
+	if got := tf.lines.offset(); got != 0 {
+		t.Errorf("offset after delivering the last line = %d, want 0", got)
+	}
+
+	settleBatch(&domain.DataBatch{Messages: []domain.UDPMessage{*first}}, true)
+	if got, want := tf.lines.offset(), int64(len("first\ndrop me\n\nlast\n")); got != want {
+		t.Errorf("offset after delivering every line = %d, want %d", got, want)
+	}
+}
+
+func TestLineTracker(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(lt *lineTracker, lines []*pendingLine)
+		want    int64
+		pending int
+	}{
+		{
+			name: "in order",
+			run: func(lt *lineTracker, lines []*pendingLine) {
+				lt.deliver(lines[0])
+				lt.deliver(lines[1])
+			},
+			want:    20,
+			pending: 1,
+		},
+		{
+			name: "gap holds later lines",
+			run: func(lt *lineTracker, lines []*pendingLine) {
+				lt.deliver(lines[1])
+				lt.deliver(lines[2])
+			},
+			want:    0,
+			pending: 3,
+		},
+		{
+			name: "gap filled",
+			run: func(lt *lineTracker, lines []*pendingLine) {
+				lt.deliver(lines[2])
+				lt.deliver(lines[0])
+				lt.deliver(lines[1])
+			},
+			want:    30,
+			pending: 0,
+		},
+		{
+			name: "failure holds the offset",
+			run: func(lt *lineTracker, lines []*pendingLine) {
+				lt.deliver(lines[0])
+				lt.fail(lines[1])
+				lt.deliver(lines[2])
+				lt.skip(40)
+			},
+			want:    10,
+			pending: 0,
+		},
+		{
+			name: "reset ignores earlier lines",
+			run: func(lt *lineTracker, lines []*pendingLine) {
+				lt.reset(0)
+				lt.deliver(lines[0])
+				lt.skip(5)
+			},
+			want:    5,
+			pending: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lt := newLineTracker(0)
+			lines := []*pendingLine{lt.track(10), lt.track(20), lt.track(30)}
+			tt.run(lt, lines)
+			if got := lt.offset(); got != tt.want {
+				t.Errorf("offset() = %d, want %d", got, tt.want)
+			}
+			if got := lt.pending.Len(); got != tt.pending {
+				t.Errorf("pending lines = %d, want %d", got, tt.pending)
+			}
+		})
+	}
+}
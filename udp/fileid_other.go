@@ -0,0 +1,10 @@
+//go:build !unix
+
+package udp
+
+import "os"
+
+// fileIdentity falls back to the path on platforms without inodes
+func fileIdentity(path string, info os.FileInfo) fileID {
+	return pathIdentity(path)
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package udp
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity identifies a file by device and inode so renames are followed
+func fileIdentity(path string, info os.FileInfo) fileID {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fileID{Device: uint64(stat.Dev), Inode: uint64(stat.Ino)}
+	}
+	return pathIdentity(path)
+}
@@ -0,0 +1,184 @@
+package udp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/decoders"
+	"github.com/n0needt0/bytefreezer-proxy/domain"
+	"github.com/n0needt0/go-goodies/log"
+)
+
+// ackTimeout bounds how long a stream client waits for its events to be batched
+const ackTimeout = 30 * time.Second
+
+// handleForwardStream serves a Fluent Forward protocol connection.
+// Chunks that request an ack are only acknowledged once all of their events
+// have been added to a batch.
+func (l *Listener) handleForwardStream(conn net.Conn, streamListener *TCPPortListener, tenantID string) {
+	decoder := decoders.NewMsgpackDecoder(bufio.NewReader(conn), l.config.UDP.MaxFrameBytes)
+
+	for {
+		value, err := decoder.Decode()
+		if err != nil {
+			if errors.Is(err, io.EOF) || l.isClosedConnError(err) {
+				log.Debugf("Forward connection from %s closed", conn.RemoteAddr())
+				return
+			}
+			log.Errorf("Forward read error from %s on port %d: %v", conn.RemoteAddr(), streamListener.port, err)
+			l.services.ProxyStats.UDPMessageErrors++
+			return
+		}
+
+		forwardMsg, err := decoders.DecodeForwardMessage(value, l.config.UDP.MaxFrameBytes)
+		if err != nil {
+			log.Warnf("Invalid forward message from %s on port %d: %v", conn.RemoteAddr(), streamListener.port, err)
+			l.services.ProxyStats.ParseErrors++
+			continue
+		}
+
+		msgTenantID, msgDatasetID := routeTag(streamListener.tagRules, forwardMsg.Tag, tenantID, streamListener.datasetID)
+
+		now := time.Now()
+		batched := newBatchWaiter()
+		delivered := true
+		for _, event := range forwardMsg.Events {
+			data, err := decoders.ForwardEventJSON(forwardMsg.Tag, event)
+			if err != nil {
+				l.services.ProxyStats.ParseErrors++
+				continue
+			}
+
+			msg := &domain.UDPMessage{
+				Data:      data,
+				From:      conn.RemoteAddr().String(),
+				Timestamp: now,
+				TenantID:  msgTenantID,
+				DatasetID: msgDatasetID,
+				Format:    streamListener.format,
+				JSONMode:  streamListener.jsonMode,
+			}
+			if forwardMsg.Chunk != "" {
+				batched.add()
+				msg.OnBatched = batched.done
+			}
+
+			if !l.enqueueBlocking(msg, streamListener.pipeline) {
+				delivered = false
+				break
+			}
+		}
+
+		if forwardMsg.Chunk == "" {
+			continue
+		}
+		batched.done() // Every event has been enqueued
+		if !delivered || !l.waitBatched(batched) {
+			// Leave the chunk unacknowledged so the client retries it
+			log.Warnf("Forward chunk %s from %s was not batched, not acknowledging", forwardMsg.Chunk, conn.RemoteAddr())
+			return
+		}
+
+		if _, err := conn.Write(decoders.EncodeMsgpackMap(map[string]string{"ack": forwardMsg.Chunk})); err != nil {
+			log.Warnf("Failed to ack forward chunk %s to %s: %v", forwardMsg.Chunk, conn.RemoteAddr(), err)
+			return
+		}
+		l.services.ProxyStats.AcksSent++
+	}
+}
+
+// batchWaiter tracks the messages of one acknowledgement until they are batched.
+// It starts with a hold for the sender, released with done once every message has
+// been enqueued, so the count cannot reach zero early.
+type batchWaiter struct {
+	pending atomic.Int64
+	batched chan struct{} // Closed when the count reaches zero
+}
+
+func newBatchWaiter() *batchWaiter {
+	w := &batchWaiter{batched: make(chan struct{})}
+	w.pending.Store(1)
+	return w
+}
+
+// add counts one more message
+func (w *batchWaiter) add() {
+	w.pending.Add(1)
+}
+
+// done releases a message, or the sender's hold; it is used as OnBatched
+func (w *batchWaiter) done() {
+	if w.pending.Add(-1) == 0 {
+		close(w.batched)
+	}
+}
+
+// waitBatched waits until all enqueued messages have been batched.
+// It returns false on timeout or shutdown.
+func (l *Listener) waitBatched(batched *batchWaiter) bool {
+	timeout := time.NewTimer(ackTimeout)
+	defer timeout.Stop()
+
+	select {
+	case <-batched.batched:
+		return true
+	case <-timeout.C:
+		return false
+	case <-l.quit:
+		return false
+	}
+}
+
+// routeTag picks the tenant/dataset for a Fluentd tag; the first matching rule wins
+func routeTag(rules []config.TagRule, tag, tenantID, datasetID string) (string, string) {
+	for _, rule := range rules {
+		if !matchTag(rule.Match, tag) {
+			continue
+		}
+		if rule.TenantID != "" {
+			tenantID = rule.TenantID
+		}
+		if rule.DatasetID != "" {
+			datasetID = rule.DatasetID
+		}
+		break
+	}
+	return tenantID, datasetID
+}
+
+// matchTag matches a tag against a Fluentd-style pattern where "*" matches one
+// tag part and "**" matches zero or more parts
+func matchTag(pattern, tag string) bool {
+	return matchTagParts(strings.Split(pattern, "."), strings.Split(tag, "."))
+}
+
+func matchTagParts(pattern, tag []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case "**":
+			for i := 0; i <= len(tag); i++ {
+				if matchTagParts(pattern[1:], tag[i:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(tag) == 0 {
+				return false
+			}
+		default:
+			if len(tag) == 0 || pattern[0] != tag[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		tag = tag[1:]
+	}
+	return len(tag) == 0
+}
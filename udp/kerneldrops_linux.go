@@ -0,0 +1,66 @@
+//go:build linux
+
+package udp
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// kernelDropCounts reads the drop counter of every UDP socket from /proc/net/udp
+// and /proc/net/udp6, keyed by socket inode
+func kernelDropCounts() (map[uint64]int64, error) {
+	drops := make(map[uint64]int64)
+
+	for _, path := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // IPv6 disabled
+			}
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Scan() // Header
+		for scanner.Scan() {
+			// sl local rem st tx:rx tr:when retrnsmt uid timeout inode ref pointer drops
+			fields := bytes.Fields(scanner.Bytes())
+			if len(fields) < 13 {
+				continue
+			}
+			inode, err := strconv.ParseUint(string(fields[9]), 10, 64)
+			if err != nil {
+				continue
+			}
+			count, err := strconv.ParseInt(string(fields[12]), 10, 64)
+			if err != nil {
+				continue
+			}
+			drops[inode] = count
+		}
+	}
+	return drops, nil
+}
+
+// socketInode returns the inode that identifies a socket in /proc/net
+func socketInode(conn *net.UDPConn) (uint64, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var stat unix.Stat_t
+	var statErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		statErr = unix.Fstat(int(fd), &stat)
+	}); err != nil {
+		return 0, err
+	}
+	return stat.Ino, statErr
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package udp
+
+import "net"
+
+// kernelDropCounts is only available on Linux
+func kernelDropCounts() (map[uint64]int64, error) {
+	return nil, errKernelDropsUnsupported
+}
+
+// socketInode is only available on Linux
+func socketInode(conn *net.UDPConn) (uint64, error) {
+	return 0, errKernelDropsUnsupported
+}
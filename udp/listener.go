@@ -3,25 +3,40 @@ package udp
 import (
 	"bytes"
 	"compress/gzip"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"net"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/decoders"
 	"github.com/n0needt0/bytefreezer-proxy/domain"
+	"github.com/n0needt0/bytefreezer-proxy/pipeline"
 	"github.com/n0needt0/bytefreezer-proxy/services"
 	"github.com/n0needt0/go-goodies/log"
 )
 
+// errBatchReadUnsupported is returned where recvmmsg batch reading is not available
+var errBatchReadUnsupported = errors.New("reuseport_readers is only supported on Linux")
+
+// errKernelDropsUnsupported is returned where per-socket drop counters cannot be read
+var errKernelDropsUnsupported = errors.New("kernel drop counters are only available on Linux")
+
 // Listener represents a UDP listener that collects data and forwards to bytefreezer-receiver
 type Listener struct {
 	services     *services.Services
 	config       *config.Config
 	listeners    []*UDPPortListener
+	streams      []*TCPPortListener
+	otlp         []*OTLPPortListener
+	files        *fileTailer
+	conns        map[net.Conn]struct{}
+	connsMu      sync.Mutex
+	ingestMu     sync.Mutex // Serializes Ingest so its capacity check holds for the whole request
 	quit         chan struct{}
 	batchChannel chan *domain.UDPMessage
 	bufferPool   sync.Pool
@@ -32,32 +47,132 @@ type Listener struct {
 
 // UDPPortListener represents a single UDP port listener
 type UDPPortListener struct {
-	port      int
-	tenantID  string
-	datasetID string
-	addr      *net.UDPAddr
-	conn      *net.UDPConn
+	index         int // Position in udp.listeners
+	port          int
+	tenantID      string
+	datasetID     string
+	format        string
+	jsonMode      string
+	pipeline      *pipeline.Pipeline
+	addr          *net.UDPAddr
+	conn          *net.UDPConn
+	decoder       decoders.Decoder
+	multiline     *multilineAssembler
+	batchConns    []*net.UDPConn // SO_REUSEPORT sockets read with recvmmsg
+	bindInterface string         // Optional SO_BINDTODEVICE interface
+	multicast     []config.MulticastGroup
+	proxyProtocol *proxyProtocol // Optional: expect PROXY protocol v2 headers
+	path          string         // Unixgram only: socket path
+	socketMode    string
+	socketOwner   string
+	unixConn      *net.UnixConn
 }
 
 // NewListener creates a new UDP listener
 func NewListener(services *services.Services, cfg *config.Config) *Listener {
 	var portListeners []*UDPPortListener
+	var streamListeners []*TCPPortListener
+	var otlpListeners []*OTLPPortListener
+	var fileInputs []*FileInput
 
 	// Create listeners for each configured port
-	for _, udpListener := range cfg.UDP.Listeners {
+	for index, udpListener := range cfg.UDP.Listeners {
 		tenantID := udpListener.TenantID
 		if tenantID == "" {
 			tenantID = cfg.TenantID // Use global tenant if not specified
 		}
 
+		if isUnixProtocol(udpListener.Protocol) && udpListener.Path == "" {
+			log.Errorf("Skipping %s listener for dataset %s: no socket path configured", udpListener.Protocol, udpListener.DatasetID)
+			continue
+		}
+
+		pipe, err := pipeline.New(listenerStages(udpListener))
+		if err != nil {
+			log.Errorf("Skipping listener on port %d for dataset %s: pipeline %v", udpListener.Port, udpListener.DatasetID, err)
+			continue
+		}
+
+		if strings.ToLower(udpListener.Protocol) == ProtocolFile {
+			if len(udpListener.Paths) == 0 {
+				log.Errorf("Skipping file input for dataset %s: no paths configured", udpListener.DatasetID)
+				continue
+			}
+			fileInput := newFileInput(udpListener, tenantID)
+			fileInput.index = index
+			fileInput.pipeline = pipe
+			fileInputs = append(fileInputs, fileInput)
+			continue
+		}
+
+		ip, err := bindIP(cfg, udpListener)
+		if err != nil && !isUnixProtocol(udpListener.Protocol) {
+			log.Errorf("Skipping listener on port %d for dataset %s: %v", udpListener.Port, udpListener.DatasetID, err)
+			continue
+		}
+
+		var proxy *proxyProtocol
+		if !isUnixProtocol(udpListener.Protocol) {
+			proxy, err = newProxyProtocol(udpListener.ProxyProtocol)
+			if err != nil {
+				log.Errorf("Skipping listener on port %d for dataset %s: %v", udpListener.Port, udpListener.DatasetID, err)
+				continue
+			}
+			if proxy != nil && len(proxy.trusted) == 0 {
+				log.Errorf("PROXY protocol on port %d has no trusted_cidrs, all senders will be rejected", udpListener.Port)
+			}
+		}
+
+		if isStreamProtocol(udpListener.Protocol) {
+			streamListener := newTCPPortListener(udpListener, tenantID, ip)
+			streamListener.index = index
+			streamListener.proxyProtocol = proxy
+			streamListener.pipeline = pipe
+			log.Debugf("Created stream listener - Port: %d, TenantID: '%s', DatasetID: '%s', Framing: '%s'",
+				streamListener.port, streamListener.tenantID, streamListener.datasetID, streamListener.framing)
+			streamListeners = append(streamListeners, streamListener)
+			continue
+		}
+
+		if isOTLPProtocol(udpListener.Protocol) {
+			otlpListener := newOTLPPortListener(udpListener, tenantID, ip)
+			otlpListener.index = index
+			otlpListener.pipeline = pipe
+			log.Debugf("Created OTLP receiver - Port: %d, TenantID: '%s', DatasetID: '%s', Protocol: '%s'",
+				otlpListener.port, otlpListener.tenantID, otlpListener.datasetID, otlpListener.protocol)
+			otlpListeners = append(otlpListeners, otlpListener)
+			continue
+		}
+
 		portListener := &UDPPortListener{
+			index:     index,
 			port:      udpListener.Port,
 			tenantID:  tenantID,
 			datasetID: udpListener.DatasetID,
+			format:    formatOf(udpListener),
+			jsonMode:  jsonModeOf(udpListener),
+			pipeline:  pipe,
 			addr: &net.UDPAddr{
-				IP:   net.ParseIP(cfg.UDP.Host),
+				IP:   ip,
 				Port: udpListener.Port,
 			},
+			bindInterface: udpListener.Interface,
+			multicast:     udpListener.Multicast,
+			proxyProtocol: proxy,
+		}
+		if strings.ToLower(udpListener.Protocol) == ProtocolUnixgram {
+			portListener.path = udpListener.Path
+			portListener.socketMode = udpListener.SocketMode
+			portListener.socketOwner = udpListener.SocketOwner
+		}
+
+		portListener.decoder = newDecoder(cfg, udpListener, portListener.format)
+		if portListener.decoder == nil {
+			multiline, err := newMultilineAssembler(udpListener.Multiline, cfg.UDP.MaxFrameBytes)
+			if err != nil {
+				log.Errorf("Ignoring multiline rules for dataset %s: %v", udpListener.DatasetID, err)
+			}
+			portListener.multiline = multiline
 		}
 
 		// Debug log to verify values are set
@@ -66,19 +181,27 @@ func NewListener(services *services.Services, cfg *config.Config) *Listener {
 		portListeners = append(portListeners, portListener)
 	}
 
-	return &Listener{
+	l := &Listener{
 		services:     services,
 		config:       cfg,
 		listeners:    portListeners,
+		streams:      streamListeners,
+		otlp:         otlpListeners,
+		conns:        make(map[net.Conn]struct{}),
 		quit:         make(chan struct{}),
 		batchChannel: make(chan *domain.UDPMessage, 1000), // Buffer for incoming messages
 		bufferPool: sync.Pool{
 			New: func() interface{} {
-				return make([]byte, cfg.UDP.ReadBufferSizeBytes)
+				// One spare byte reveals datagrams longer than max_datagram_bytes
+				return make([]byte, cfg.UDP.MaxDatagramBytes+1)
 			},
 		},
 		forwarder: NewForwarder(services, cfg),
 	}
+	if len(fileInputs) > 0 {
+		l.files = newFileTailer(l, fileInputs)
+	}
+	return l
 }
 
 // Start starts the UDP listener
@@ -88,20 +211,47 @@ func (l *Listener) Start() error {
 		return nil
 	}
 
-	if len(l.listeners) == 0 {
+	if len(l.listeners) == 0 && len(l.streams) == 0 && len(l.otlp) == 0 && l.files == nil {
+		// Keep the forwarder running so pushed (HTTP) records are still batched
 		log.Info("No UDP listeners configured")
-		return nil
 	}
 
 	// Start listeners for each port
 	for _, portListener := range l.listeners {
-		var err error
-		portListener.conn, err = net.ListenUDP("udp", portListener.addr)
+		if portListener.path != "" {
+			if err := l.startUnixgramListener(portListener); err != nil {
+				l.Stop()
+				return err
+			}
+			continue
+		}
+
+		// The kernel hands every multicast datagram to each SO_REUSEPORT socket,
+		// so multicast listeners keep a single reader
+		if l.config.UDP.ReusePortReaders > 0 && len(portListener.multicast) == 0 {
+			err := l.startBatchReaders(portListener)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, errBatchReadUnsupported) {
+				l.Stop()
+				return err
+			}
+			log.Warnf("%v, using a single reader on port %d", err, portListener.port)
+		}
+
+		packetConn, err := listenConfig(portListener.bindInterface, false).ListenPacket(context.Background(), "udp", portListener.addr.String())
 		if err != nil {
 			// Clean up any already started listeners
 			l.Stop()
 			return fmt.Errorf("failed to listen on UDP %s: %w", portListener.addr.String(), err)
 		}
+		portListener.conn = packetConn.(*net.UDPConn)
+
+		if err := joinMulticastGroups(portListener.conn, portListener); err != nil {
+			l.Stop()
+			return err
+		}
 
 		if err := portListener.conn.SetReadBuffer(l.config.UDP.ReadBufferSizeBytes); err != nil {
 			portListener.conn.Close()
@@ -109,8 +259,7 @@ func (l *Listener) Start() error {
 			return fmt.Errorf("failed to set read buffer for %s: %w", portListener.addr.String(), err)
 		}
 
-		log.Info("UDP server listening on " + portListener.addr.IP.String() + ":" +
-			fmt.Sprintf("%d", portListener.addr.Port) + " (tenant: " + portListener.tenantID +
+		log.Info("UDP server listening on " + portListener.conn.LocalAddr().String() + " (tenant: " + portListener.tenantID +
 			", dataset: " + portListener.datasetID + ")")
 
 		// Start message handler for this port
@@ -121,6 +270,31 @@ func (l *Listener) Start() error {
 		}(portListener)
 	}
 
+	// Start stream listeners
+	for _, streamListener := range l.streams {
+		if err := l.startStreamListener(streamListener); err != nil {
+			l.Stop()
+			return err
+		}
+	}
+
+	// Start OTLP receivers
+	for _, otlpListener := range l.otlp {
+		if err := l.startOTLPListener(otlpListener); err != nil {
+			l.Stop()
+			return err
+		}
+	}
+
+	// Start tailing file inputs
+	if l.files != nil {
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			l.files.run()
+		}()
+	}
+
 	// Start the forwarder
 	l.wg.Add(1)
 	go func() {
@@ -143,6 +317,26 @@ func (l *Listener) Stop() error {
 			if portListener.conn != nil {
 				portListener.conn.Close()
 			}
+			for _, conn := range portListener.batchConns {
+				conn.Close()
+			}
+			if portListener.unixConn != nil {
+				portListener.unixConn.Close()
+				os.Remove(portListener.path)
+			}
+		}
+
+		// Close stream listeners and any open connections
+		for _, streamListener := range l.streams {
+			if streamListener.listener != nil {
+				streamListener.listener.Close()
+			}
+		}
+		l.closeConns()
+
+		// Close OTLP receivers
+		for _, otlpListener := range l.otlp {
+			otlpListener.stop()
 		}
 
 		// Stop the forwarder
@@ -152,12 +346,21 @@ func (l *Listener) Stop() error {
 	})
 
 	l.wg.Wait()
+
+	// Persist file offsets committed by the final batches
+	if l.files != nil {
+		l.files.saveCheckpoints()
+		l.files.close()
+	}
+
 	log.Info("UDP listener shut down gracefully")
 	return nil
 }
 
 // handleMessagesForPort handles incoming UDP messages for a specific port
 func (l *Listener) handleMessagesForPort(portListener *UDPPortListener) {
+	defer l.flushMultiline(portListener)
+
 	for {
 		select {
 		case <-l.quit:
@@ -176,6 +379,7 @@ func (l *Listener) handleMessagesForPort(portListener *UDPPortListener) {
 
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 				// Timeout is expected, continue
+				l.expireListenerState(portListener)
 				continue
 			}
 
@@ -194,14 +398,44 @@ func (l *Listener) handleMessagesForPort(portListener *UDPPortListener) {
 			continue
 		}
 
-		// Process the message with port-specific tenant/dataset info
-		l.processMessageWithContext(buf[:readLen], remoteAddr, portListener.tenantID, portListener.datasetID)
+		l.dispatchDatagram(portListener, l.datagramPayload(buf, readLen, remoteAddr), remoteAddr)
 		l.deallocateBuffer(buf)
 	}
 }
 
-// processMessageWithContext processes a single UDP message with tenant/dataset context
-func (l *Listener) processMessageWithContext(data []byte, from *net.UDPAddr, tenantID, datasetID string) {
+// dispatchDatagram hands a received datagram to the listener's decoder, multiline
+// assembler or, by default, straight to batching
+func (l *Listener) dispatchDatagram(portListener *UDPPortListener, data []byte, from net.Addr) {
+	if portListener.proxyProtocol != nil {
+		var ok bool
+		if data, from, ok = l.stripProxyHeader(portListener, data, from); !ok {
+			return
+		}
+	}
+
+	switch {
+	case portListener.decoder != nil:
+		l.handleDatagram(portListener, data, from)
+	case portListener.multiline != nil:
+		l.handleMultiline(portListener, data, from)
+	default:
+		// Process the message with port-specific tenant/dataset info
+		l.processMessageWithContext(data, from, portListener.tenantID, portListener.datasetID, portListener.format, portListener.jsonMode, portListener.pipeline)
+	}
+}
+
+// expireListenerState ages out decoder and multiline state while a socket is idle
+func (l *Listener) expireListenerState(portListener *UDPPortListener) {
+	if portListener.decoder != nil {
+		l.expireDecoderState(portListener)
+	}
+	if portListener.multiline != nil {
+		l.expireMultiline(portListener)
+	}
+}
+
+// processMessageWithContext processes a single UDP message or stream frame with tenant/dataset context
+func (l *Listener) processMessageWithContext(data []byte, from net.Addr, tenantID, datasetID, format, jsonMode string, pipe *pipeline.Pipeline) {
 	// Clean up the payload
 	payload := bytes.TrimSpace(data)
 	payload = bytes.Trim(payload, "\x08\x00")
@@ -217,9 +451,15 @@ func (l *Listener) processMessageWithContext(data []byte, from *net.UDPAddr, ten
 		Timestamp: time.Now(),
 		TenantID:  tenantID,
 		DatasetID: datasetID,
+		Format:    format,
+		JSONMode:  jsonMode,
 	}
 	copy(msg.Data, payload)
 
+	if !l.applyPipeline(pipe, msg) {
+		return
+	}
+
 	// Try to send to batch channel (non-blocking)
 	select {
 	case l.batchChannel <- msg:
@@ -233,6 +473,64 @@ func (l *Listener) processMessageWithContext(data []byte, from *net.UDPAddr, ten
 	}
 }
 
+// enqueueBlocking waits for room in the batch channel. It is used by protocols that
+// acknowledge delivery, so messages are never dropped; it returns false on shutdown.
+// Messages dropped by the listener pipeline count as delivered.
+func (l *Listener) enqueueBlocking(msg *domain.UDPMessage, pipe *pipeline.Pipeline) bool {
+	if !l.applyPipeline(pipe, msg) {
+		return true
+	}
+
+	select {
+	case l.batchChannel <- msg:
+		l.services.ProxyStats.UDPMessagesReceived++
+		l.services.ProxyStats.BytesReceived += int64(len(msg.Data))
+		l.services.ProxyStats.LastActivity = time.Now()
+		return true
+	case <-l.quit:
+		return false
+	}
+}
+
+// Ingest enqueues messages received outside the listener sockets (e.g. HTTP push)
+// into the batching pipeline. A channel without room for the request is reported to
+// the caller before anything is queued; requests larger than the channel wait for
+// the batcher to drain it.
+func (l *Listener) Ingest(msgs []*domain.UDPMessage) (int, error) {
+	select {
+	case <-l.quit:
+		return 0, services.ErrIngestUnavailable
+	default:
+	}
+
+	l.ingestMu.Lock()
+	defer l.ingestMu.Unlock()
+
+	if free := cap(l.batchChannel) - len(l.batchChannel); free < min(len(msgs), cap(l.batchChannel)) {
+		return 0, services.ErrIngestQueueFull
+	}
+	for i, msg := range msgs {
+		select {
+		case l.batchChannel <- msg:
+		case <-l.quit:
+			return i, services.ErrIngestUnavailable
+		}
+	}
+	return len(msgs), nil
+}
+
+// datagramPayload returns the datagram read into a pooled buffer, cut to
+// max_datagram_bytes. A datagram that filled the spare byte was truncated.
+func (l *Listener) datagramPayload(buf []byte, readLen int, from net.Addr) []byte {
+	maxBytes := l.config.UDP.MaxDatagramBytes
+	if readLen > maxBytes {
+		log.Debugf("Truncated datagram from %s to %d bytes", from, maxBytes)
+		l.services.ProxyStats.DatagramsTruncated++
+		return buf[:maxBytes]
+	}
+	return buf[:readLen]
+}
+
 // allocateBuffer gets a buffer from the pool
 func (l *Listener) allocateBuffer() []byte {
 	return l.bufferPool.Get().([]byte)
@@ -326,6 +624,9 @@ func (f *Forwarder) Start(messageChannel <-chan *domain.UDPMessage) {
 			batch.Messages = append(batch.Messages, *msg)
 			batch.LineCount++
 			batch.TotalBytes += int64(len(msg.Data))
+			if msg.OnBatched != nil {
+				msg.OnBatched()
+			}
 
 			// Check if batch is ready to send
 			shouldSend := false
@@ -367,33 +668,13 @@ func (f *Forwarder) Stop() {
 
 // sendBatch sends a batch to bytefreezer-receiver
 func (f *Forwarder) sendBatch(batch *domain.DataBatch) {
+	delivered := false
+	defer func() { settleBatch(batch, delivered) }()
+
 	// Convert messages to NDJSON
 	var ndjsonData bytes.Buffer
-	for _, msg := range batch.Messages {
-		// Try to parse as JSON first
-		var jsonObj interface{}
-		if err := json.Unmarshal(msg.Data, &jsonObj); err == nil {
-			// Valid JSON, marshal it to ensure consistent formatting
-			if jsonBytes, err := json.Marshal(jsonObj); err == nil {
-				ndjsonData.Write(jsonBytes)
-				ndjsonData.WriteByte('\n')
-			} else {
-				// Fallback to raw data
-				ndjsonData.Write(msg.Data)
-				ndjsonData.WriteByte('\n')
-			}
-		} else {
-			// Not valid JSON, create a JSON envelope
-			envelope := map[string]interface{}{
-				"message":   string(msg.Data),
-				"source":    msg.From,
-				"timestamp": msg.Timestamp.Format(time.RFC3339Nano),
-			}
-			if jsonBytes, err := json.Marshal(envelope); err == nil {
-				ndjsonData.Write(jsonBytes)
-				ndjsonData.WriteByte('\n')
-			}
-		}
+	for i := range batch.Messages {
+		f.encodeMessage(&ndjsonData, &batch.Messages[i])
 	}
 
 	// Compress if enabled
@@ -438,6 +719,7 @@ func (f *Forwarder) sendBatch(batch *domain.DataBatch) {
 			if spoolErr := f.services.SpoolingService.SpoolData(batch.TenantID, batch.DatasetID, finalData, err.Error()); spoolErr != nil {
 				log.Errorf("Failed to spool batch %s: %v", batch.ID, spoolErr)
 			} else {
+				delivered = true
 				log.Debugf("Spooled failed batch %s for tenant=%s, dataset=%s", batch.ID, batch.TenantID, batch.DatasetID)
 			}
 		}
@@ -447,6 +729,7 @@ func (f *Forwarder) sendBatch(batch *domain.DataBatch) {
 			f.config.SOCAlertClient.SendReceiverForwardingFailureAlert(f.config.Receiver.BaseURL, err)
 		}
 	} else {
+		delivered = true
 		f.services.ProxyStats.BatchesForwarded++
 		f.services.ProxyStats.BytesForwarded += int64(len(finalData))
 		log.Debugf("Successfully sent batch %s (%d messages, %d bytes)", batch.ID, batch.LineCount, len(finalData))
@@ -455,6 +738,19 @@ func (f *Forwarder) sendBatch(batch *domain.DataBatch) {
 	f.services.ProxyStats.BatchesCreated++
 }
 
+// settleBatch reports the outcome of a batch to the messages that asked for it
+func settleBatch(batch *domain.DataBatch, delivered bool) {
+	for i := range batch.Messages {
+		msg := &batch.Messages[i]
+		switch {
+		case delivered && msg.OnDelivered != nil:
+			msg.OnDelivered()
+		case !delivered && msg.OnFailed != nil:
+			msg.OnFailed()
+		}
+	}
+}
+
 // sendToReceiver sends the batch to bytefreezer-receiver
 func (f *Forwarder) sendToReceiver(batch *domain.DataBatch) error {
 	// Use HTTP forwarder from services
@@ -0,0 +1,38 @@
+package udp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/n0needt0/bytefreezer-proxy/domain"
+	"github.com/n0needt0/bytefreezer-proxy/services"
+)
+
+func TestIngestAllOrNothing(t *testing.T) {
+	l, batchChannel := newTestListener(t)
+	messages := func(n int) []*domain.UDPMessage {
+		msgs := make([]*domain.UDPMessage, n)
+		for i := range msgs {
+			msgs[i] = &domain.UDPMessage{Data: []byte("record")}
+		}
+		return msgs
+	}
+
+	if accepted, err := l.Ingest(messages(10)); err != nil || accepted != 10 {
+		t.Fatalf("Ingest(10) = %d, %v, want 10, nil", accepted, err)
+	}
+
+	// Six free slots cannot take seven records, so none are queued
+	accepted, err := l.Ingest(messages(7))
+	if !errors.Is(err, services.ErrIngestQueueFull) || accepted != 0 {
+		t.Fatalf("Ingest(7) = %d, %v, want 0, %v", accepted, err, services.ErrIngestQueueFull)
+	}
+	if len(batchChannel) != 10 {
+		t.Errorf("queued %d records, want 10", len(batchChannel))
+	}
+
+	close(l.quit)
+	if _, err := l.Ingest(messages(1)); !errors.Is(err, services.ErrIngestUnavailable) {
+		t.Errorf("Ingest() after stop error = %v, want %v", err, services.ErrIngestUnavailable)
+	}
+}
@@ -0,0 +1,101 @@
+package udp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/decoders"
+	"github.com/n0needt0/bytefreezer-proxy/domain"
+	"github.com/n0needt0/go-goodies/log"
+)
+
+// lumberjackKeepaliveInterval is how often an empty ACK is sent while a window is
+// still being batched, so Beats does not time out the connection
+const lumberjackKeepaliveInterval = 5 * time.Second
+
+// handleLumberjackStream serves a Beats (Lumberjack v2) connection.
+// Each window is acknowledged only once all of its events have been added to a batch.
+func (l *Listener) handleLumberjackStream(conn net.Conn, streamListener *TCPPortListener, tenantID, datasetID string) {
+	reader := decoders.NewLumberjackReader(bufio.NewReader(conn), l.config.UDP.MaxFrameBytes,
+		streamListener.lumberjack.MaxWindowEvents, streamListener.lumberjack.MaxWindowBytes)
+
+	for {
+		batch, err := reader.ReadBatch()
+		if err != nil {
+			if errors.Is(err, io.EOF) || l.isClosedConnError(err) {
+				log.Debugf("Lumberjack connection from %s closed", conn.RemoteAddr())
+				return
+			}
+			if errors.Is(err, decoders.ErrLumberjackProtocol) {
+				l.services.ProxyStats.ParseErrors++
+			} else {
+				l.services.ProxyStats.UDPMessageErrors++
+			}
+			log.Errorf("Lumberjack read error from %s on port %d: %v", conn.RemoteAddr(), streamListener.port, err)
+			return
+		}
+
+		now := time.Now()
+		batched := newBatchWaiter()
+		for _, event := range batch.Events {
+			batched.add()
+			msg := &domain.UDPMessage{
+				Data:      event,
+				From:      conn.RemoteAddr().String(),
+				Timestamp: now,
+				TenantID:  tenantID,
+				DatasetID: datasetID,
+				Format:    streamListener.format,
+				JSONMode:  streamListener.jsonMode,
+				OnBatched: batched.done,
+			}
+
+			if !l.enqueueBlocking(msg, streamListener.pipeline) {
+				// Shutting down; leave the window unacknowledged so Beats resends it
+				return
+			}
+		}
+
+		batched.done() // Every event has been enqueued
+		if !l.waitBatchedWithKeepalive(conn, batched) {
+			log.Warnf("Lumberjack window ending at seq %d from %s was not batched, not acknowledging",
+				batch.LastSeq, conn.RemoteAddr())
+			return
+		}
+
+		if len(batch.Events) == 0 {
+			continue
+		}
+		if _, err := conn.Write(decoders.EncodeLumberjackAck(batch.LastSeq)); err != nil {
+			log.Warnf("Failed to ack lumberjack seq %d to %s: %v", batch.LastSeq, conn.RemoteAddr(), err)
+			return
+		}
+		l.services.ProxyStats.AcksSent++
+	}
+}
+
+// waitBatchedWithKeepalive waits like waitBatched, sending empty ACKs while it waits
+func (l *Listener) waitBatchedWithKeepalive(conn net.Conn, batched *batchWaiter) bool {
+	keepalive := time.NewTicker(lumberjackKeepaliveInterval)
+	defer keepalive.Stop()
+	timeout := time.NewTimer(ackTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-batched.batched:
+			return true
+		case <-keepalive.C:
+			if _, err := conn.Write(decoders.EncodeLumberjackAck(0)); err != nil {
+				return false
+			}
+		case <-timeout.C:
+			return false
+		case <-l.quit:
+			return false
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/n0needt0/go-goodies/log"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// multicastJoiner is implemented by ipv4.PacketConn and ipv6.PacketConn
+type multicastJoiner interface {
+	JoinGroup(ifi *net.Interface, group net.Addr) error
+	JoinSourceSpecificGroup(ifi *net.Interface, group, source net.Addr) error
+}
+
+// joinMulticastGroups joins the listener's multicast groups on a socket. Without
+// sources the whole group is joined, otherwise one source-specific membership is
+// added per source.
+func joinMulticastGroups(conn *net.UDPConn, portListener *UDPPortListener) error {
+	for _, group := range portListener.multicast {
+		groupIP := net.ParseIP(group.Group)
+		if groupIP == nil || !groupIP.IsMulticast() {
+			return fmt.Errorf("invalid multicast group %q on port %d", group.Group, portListener.port)
+		}
+
+		ifaceName := group.Interface
+		if ifaceName == "" {
+			ifaceName = portListener.bindInterface
+		}
+		var iface *net.Interface
+		if ifaceName != "" {
+			var err error
+			if iface, err = net.InterfaceByName(ifaceName); err != nil {
+				return fmt.Errorf("invalid multicast interface %q on port %d: %w", ifaceName, portListener.port, err)
+			}
+		}
+
+		var joiner multicastJoiner = ipv6.NewPacketConn(conn)
+		if groupIP.To4() != nil {
+			joiner = ipv4.NewPacketConn(conn)
+		}
+
+		groupAddr := &net.UDPAddr{IP: groupIP}
+		if len(group.Sources) == 0 {
+			if err := joiner.JoinGroup(iface, groupAddr); err != nil {
+				return fmt.Errorf("failed to join multicast group %s on port %d: %w", group.Group, portListener.port, err)
+			}
+		}
+		for _, source := range group.Sources {
+			sourceIP := net.ParseIP(source)
+			if sourceIP == nil {
+				return fmt.Errorf("invalid multicast source %q for group %s", source, group.Group)
+			}
+			if err := joiner.JoinSourceSpecificGroup(iface, groupAddr, &net.UDPAddr{IP: sourceIP}); err != nil {
+				return fmt.Errorf("failed to join multicast group %s from %s on port %d: %w",
+					group.Group, source, portListener.port, err)
+			}
+		}
+
+		joined := group.Group
+		if len(group.Sources) > 0 {
+			joined += " (sources: " + strings.Join(group.Sources, ", ") + ")"
+		}
+		if ifaceName != "" {
+			joined += " on " + ifaceName
+		}
+		log.Info("Joined multicast group " + joined + " for port " + fmt.Sprintf("%d", portListener.port))
+	}
+	return nil
+}
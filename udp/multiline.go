@@ -0,0 +1,195 @@
+package udp
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"net"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+)
+
+// multilineExpireInterval bounds how often pending events are checked against the flush timeout
+const multilineExpireInterval = 100 * time.Millisecond
+
+// multilineEvent is an event being reassembled from one sender's datagrams
+type multilineEvent struct {
+	key      string
+	from     net.Addr
+	data     []byte
+	lines    int
+	lastSeen time.Time
+	element  *list.Element // Position in multilineAssembler.idle
+}
+
+// multilineAssembler joins consecutive datagrams from the same sender into single
+// events, such as stack traces sent one line per datagram. It is shared by all
+// readers of a port. Senders are spoofable, so the number of open events is bounded.
+type multilineAssembler struct {
+	mu           sync.Mutex
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+	maxLines     int
+	maxBytes     int
+	maxPending   int
+	flushTimeout time.Duration
+	pending      map[string]*multilineEvent
+	idle         *list.List // Pending events, longest idle first
+	nextExpire   time.Time
+}
+
+// newMultilineAssembler compiles a listener's multiline rules. It returns nil when
+// no pattern is configured.
+func newMultilineAssembler(rules config.Multiline, maxBytes int) (*multilineAssembler, error) {
+	if rules.StartPattern == "" && rules.ContinuationPattern == "" {
+		return nil, nil
+	}
+
+	a := &multilineAssembler{
+		maxLines:     rules.MaxLines,
+		maxBytes:     maxBytes,
+		maxPending:   rules.MaxPendingSenders,
+		flushTimeout: time.Duration(rules.FlushTimeoutSeconds) * time.Second,
+		pending:      make(map[string]*multilineEvent),
+		idle:         list.New(),
+	}
+
+	var err error
+	if rules.StartPattern != "" {
+		if a.start, err = regexp.Compile(rules.StartPattern); err != nil {
+			return nil, fmt.Errorf("invalid start_pattern: %w", err)
+		}
+	}
+	if rules.ContinuationPattern != "" {
+		if a.continuation, err = regexp.Compile(rules.ContinuationPattern); err != nil {
+			return nil, fmt.Errorf("invalid continuation_pattern: %w", err)
+		}
+	}
+	return a, nil
+}
+
+// continues reports whether a line belongs to the sender's current event
+func (a *multilineAssembler) continues(line []byte) bool {
+	if a.start != nil && a.start.Match(line) {
+		return false
+	}
+	if a.continuation != nil {
+		return a.continuation.Match(line)
+	}
+	return true
+}
+
+// add appends a datagram to its sender's event and returns the events it completes
+func (a *multilineAssembler) add(data []byte, from net.Addr, now time.Time) []*multilineEvent {
+	// Keep leading whitespace, continuation patterns usually depend on it
+	line := bytes.TrimRight(data, "\r\n\x00")
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var done []*multilineEvent
+	key := from.String()
+	event := a.pending[key]
+
+	if event != nil && (!a.continues(line) || (a.maxBytes > 0 && len(event.data)+1+len(line) > a.maxBytes)) {
+		done = append(done, a.remove(event))
+		event = nil
+	}
+
+	if event == nil {
+		if a.maxPending > 0 && len(a.pending) >= a.maxPending {
+			// Make room by emitting the event of the longest idle sender
+			done = append(done, a.remove(a.idle.Front().Value.(*multilineEvent)))
+		}
+		event = &multilineEvent{key: key, from: from}
+		event.element = a.idle.PushBack(event)
+		a.pending[key] = event
+	} else {
+		event.data = append(event.data, '\n')
+		a.idle.MoveToBack(event.element)
+	}
+	event.data = append(event.data, line...)
+	event.lines++
+	event.lastSeen = now
+
+	if a.maxLines > 0 && event.lines >= a.maxLines {
+		done = append(done, a.remove(event))
+	}
+	return done
+}
+
+// remove takes an event out of the pending set and returns it. The caller holds a.mu.
+func (a *multilineAssembler) remove(event *multilineEvent) *multilineEvent {
+	a.idle.Remove(event.element)
+	delete(a.pending, event.key)
+	return event
+}
+
+// expire returns the events of senders that have been idle for the flush timeout
+func (a *multilineAssembler) expire(now time.Time) []*multilineEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.pending) == 0 || now.Before(a.nextExpire) {
+		return nil
+	}
+	a.nextExpire = now.Add(multilineExpireInterval)
+
+	var done []*multilineEvent
+	for element := a.idle.Front(); element != nil; element = a.idle.Front() {
+		event := element.Value.(*multilineEvent)
+		if now.Sub(event.lastSeen) < a.flushTimeout {
+			break
+		}
+		done = append(done, a.remove(event))
+	}
+	return done
+}
+
+// flush returns all pending events
+func (a *multilineAssembler) flush() []*multilineEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	done := make([]*multilineEvent, 0, len(a.pending))
+	for element := a.idle.Front(); element != nil; element = a.idle.Front() {
+		done = append(done, a.remove(element.Value.(*multilineEvent)))
+	}
+	return done
+}
+
+// handleMultiline adds a datagram to the listener's multiline events and emits
+// the events that are complete
+func (l *Listener) handleMultiline(portListener *UDPPortListener, data []byte, from net.Addr) {
+	now := time.Now()
+	l.emitMultiline(portListener, portListener.multiline.add(data, from, now))
+	l.emitMultiline(portListener, portListener.multiline.expire(now))
+}
+
+// expireMultiline emits the events of idle senders
+func (l *Listener) expireMultiline(portListener *UDPPortListener) {
+	l.emitMultiline(portListener, portListener.multiline.expire(time.Now()))
+}
+
+// flushMultiline emits all pending events when a read loop exits
+func (l *Listener) flushMultiline(portListener *UDPPortListener) {
+	if portListener.multiline != nil {
+		l.emitMultiline(portListener, portListener.multiline.flush())
+	}
+}
+
+// emitMultiline queues reassembled events as single records
+func (l *Listener) emitMultiline(portListener *UDPPortListener, events []*multilineEvent) {
+	for _, event := range events {
+		if event.lines > 1 {
+			l.services.ProxyStats.MultilineEvents++
+		}
+		l.processMessageWithContext(event.data, event.from, portListener.tenantID, portListener.datasetID, portListener.format, portListener.jsonMode, portListener.pipeline)
+	}
+}
@@ -0,0 +1,316 @@
+package udp
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/decoders"
+	"github.com/n0needt0/bytefreezer-proxy/domain"
+	"github.com/n0needt0/bytefreezer-proxy/pipeline"
+	"github.com/n0needt0/go-goodies/log"
+)
+
+// OTLP receiver protocols
+const (
+	ProtocolOTLPGRPC = "otlp_grpc"
+	ProtocolOTLPHTTP = "otlp_http"
+)
+
+// otlpLogsPath is the OTLP/HTTP logs endpoint
+const otlpLogsPath = "/v1/logs"
+
+// OTLP/HTTP content types
+const (
+	otlpContentTypeProtobuf = "application/x-protobuf"
+	otlpContentTypeJSON     = "application/json"
+)
+
+// errOTLPUnavailable is returned when the proxy shuts down while a request is in flight
+var errOTLPUnavailable = errors.New("proxy is shutting down")
+
+// isOTLPProtocol reports whether a listener protocol is an OTLP receiver
+func isOTLPProtocol(protocol string) bool {
+	switch strings.ToLower(protocol) {
+	case ProtocolOTLPGRPC, ProtocolOTLPHTTP:
+		return true
+	}
+	return false
+}
+
+// OTLPPortListener represents a single OTLP/gRPC or OTLP/HTTP logs receiver
+type OTLPPortListener struct {
+	index            int // Position in udp.listeners
+	port             int
+	protocol         string
+	tenantID         string
+	datasetID        string
+	format           string
+	jsonMode         string
+	pipeline         *pipeline.Pipeline
+	tls              config.TLS
+	tenantAttribute  string
+	datasetAttribute string
+	addr             *net.TCPAddr
+	bindInterface    string // Optional SO_BINDTODEVICE interface
+	boundAddr        string
+	grpcServer       *grpc.Server
+	httpServer       *http.Server
+}
+
+// newOTLPPortListener creates an OTLP receiver from its configuration entry
+func newOTLPPortListener(udpListener config.UDPListener, tenantID string, ip net.IP) *OTLPPortListener {
+	return &OTLPPortListener{
+		port:             udpListener.Port,
+		protocol:         strings.ToLower(udpListener.Protocol),
+		tenantID:         tenantID,
+		datasetID:        udpListener.DatasetID,
+		format:           formatOf(udpListener),
+		jsonMode:         jsonModeOf(udpListener),
+		tls:              udpListener.TLS,
+		tenantAttribute:  udpListener.OTLP.TenantAttribute,
+		datasetAttribute: udpListener.OTLP.DatasetAttribute,
+		addr: &net.TCPAddr{
+			IP:   ip,
+			Port: udpListener.Port,
+		},
+		bindInterface: udpListener.Interface,
+	}
+}
+
+// startOTLPListener opens the receiver socket and starts serving OTLP requests
+func (l *Listener) startOTLPListener(otlpListener *OTLPPortListener) error {
+	var serverTLSConfig *tls.Config
+	if otlpListener.tls.CertFile != "" {
+		var err error
+		serverTLSConfig, err = newServerTLSConfig(otlpListener.tls)
+		if err != nil {
+			return fmt.Errorf("failed to configure TLS for port %d: %w", otlpListener.port, err)
+		}
+	}
+
+	listener, err := listenConfig(otlpListener.bindInterface, false).Listen(context.Background(), "tcp", otlpListener.addr.String())
+	if err != nil {
+		return fmt.Errorf("failed to listen on TCP %s: %w", otlpListener.addr.String(), err)
+	}
+	otlpListener.boundAddr = listener.Addr().String()
+
+	log.Info(strings.ToUpper(otlpListener.protocol) + " receiver listening on " + listener.Addr().String() +
+		" (tenant: " + otlpListener.tenantID + ", dataset: " + otlpListener.datasetID + ")")
+
+	if otlpListener.protocol == ProtocolOTLPGRPC {
+		options := []grpc.ServerOption{grpc.MaxRecvMsgSize(l.config.UDP.MaxFrameBytes)}
+		if serverTLSConfig != nil {
+			options = append(options, grpc.Creds(credentials.NewTLS(serverTLSConfig)))
+		}
+		otlpListener.grpcServer = grpc.NewServer(options...)
+		collogspb.RegisterLogsServiceServer(otlpListener.grpcServer, &otlpLogsService{listener: l, otlp: otlpListener})
+
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			if err := otlpListener.grpcServer.Serve(listener); err != nil {
+				log.Errorf("OTLP gRPC receiver on port %d stopped: %v", otlpListener.port, err)
+			}
+		}()
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(otlpLogsPath, l.otlpHTTPHandler(otlpListener))
+	otlpListener.httpServer = &http.Server{
+		Handler:           mux,
+		TLSConfig:         serverTLSConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		var err error
+		if serverTLSConfig != nil {
+			err = otlpListener.httpServer.ServeTLS(listener, "", "")
+		} else {
+			err = otlpListener.httpServer.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("OTLP HTTP receiver on port %d stopped: %v", otlpListener.port, err)
+		}
+	}()
+	return nil
+}
+
+// stop closes the receiver and any open client connections
+func (otlpListener *OTLPPortListener) stop() {
+	if otlpListener.grpcServer != nil {
+		otlpListener.grpcServer.Stop()
+	}
+	if otlpListener.httpServer != nil {
+		otlpListener.httpServer.Close()
+	}
+}
+
+// otlpLogsService implements the OTLP LogsService gRPC server
+type otlpLogsService struct {
+	collogspb.UnimplementedLogsServiceServer
+	listener *Listener
+	otlp     *OTLPPortListener
+}
+
+// Export receives a batch of log records over gRPC
+func (s *otlpLogsService) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	from := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		from = p.Addr.String()
+	}
+
+	resp, err := s.listener.ingestOTLPLogs(s.otlp, req, from)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return resp, nil
+}
+
+// otlpHTTPHandler serves OTLP/HTTP log exports encoded as protobuf or JSON
+func (l *Listener) otlpHTTPHandler(otlpListener *OTLPPortListener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if contentType != otlpContentTypeProtobuf && contentType != otlpContentTypeJSON {
+			l.services.ProxyStats.OTLPRequestsRejected++
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		maxBytes := int64(l.config.UDP.MaxFrameBytes)
+		var body io.Reader = http.MaxBytesReader(w, r.Body, maxBytes)
+		if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+			gz, err := gzip.NewReader(body)
+			if err != nil {
+				l.services.ProxyStats.OTLPRequestsRejected++
+				http.Error(w, "invalid gzip body", http.StatusBadRequest)
+				return
+			}
+			defer gz.Close()
+			body = io.LimitReader(gz, maxBytes+1)
+		}
+
+		data, err := io.ReadAll(body)
+		if err != nil {
+			l.services.ProxyStats.OTLPRequestsRejected++
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if int64(len(data)) > maxBytes {
+			l.services.ProxyStats.OTLPRequestsRejected++
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		req := &collogspb.ExportLogsServiceRequest{}
+		if contentType == otlpContentTypeJSON {
+			err = decoders.UnmarshalOTLPLogsJSON(data, req)
+		} else {
+			err = proto.Unmarshal(data, req)
+		}
+		if err != nil {
+			l.services.ProxyStats.OTLPRequestsRejected++
+			http.Error(w, "invalid OTLP logs request", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := l.ingestOTLPLogs(otlpListener, req, r.RemoteAddr)
+		if err != nil {
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		var out []byte
+		if contentType == otlpContentTypeJSON {
+			out, err = protojson.Marshal(resp)
+		} else {
+			out, err = proto.Marshal(resp)
+		}
+		if err != nil {
+			http.Error(w, "failed to encode response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(out)
+	}
+}
+
+// ingestOTLPLogs flattens an export request and queues one message per log record.
+// Records are routed by resource attributes, falling back to the listener tenant/dataset.
+func (l *Listener) ingestOTLPLogs(otlpListener *OTLPPortListener, req *collogspb.ExportLogsServiceRequest, from string) (*collogspb.ExportLogsServiceResponse, error) {
+	records, rejected := decoders.FlattenOTLPLogs(req)
+	now := time.Now()
+
+	for _, record := range records {
+		tenantID := decoders.OTLPAttributeString(record.Resource, otlpListener.tenantAttribute)
+		if tenantID == "" {
+			tenantID = otlpListener.tenantID
+		}
+		datasetID := decoders.OTLPAttributeString(record.Resource, otlpListener.datasetAttribute)
+		if datasetID == "" {
+			datasetID = otlpListener.datasetID
+		}
+
+		msg := &domain.UDPMessage{
+			Data:      record.Data,
+			From:      from,
+			Timestamp: now,
+			TenantID:  tenantID,
+			DatasetID: datasetID,
+			Format:    otlpListener.format,
+			JSONMode:  otlpListener.jsonMode,
+		}
+		if !l.enqueueBlocking(msg, otlpListener.pipeline) {
+			return nil, errOTLPUnavailable
+		}
+		l.services.ProxyStats.OTLPRecordsReceived++
+	}
+
+	resp := &collogspb.ExportLogsServiceResponse{}
+	if rejected > 0 {
+		l.services.ProxyStats.ParseErrors += int64(rejected)
+		resp.PartialSuccess = &collogspb.ExportLogsPartialSuccess{
+			RejectedLogRecords: int64(rejected),
+			ErrorMessage:       "log records could not be encoded as JSON",
+		}
+	}
+
+	log.Debugf("Received %d OTLP log records from %s on port %d", len(records), from, otlpListener.port)
+	return resp, nil
+}
@@ -0,0 +1,95 @@
+package udp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// otlpJSONLogs is an OTLP/JSON export as sent by collectors and SDKs, with hex trace context
+const otlpJSONLogs = `{
+  "resourceLogs": [{
+    "resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "checkout"}}]},
+    "scopeLogs": [{
+      "scope": {"name": "my.library", "version": "1.0.0"},
+      "logRecords": [{
+        "timeUnixNano": "1544712660300000000",
+        "observedTimeUnixNano": "1544712660300000000",
+        "severityNumber": 10,
+        "severityText": "Information",
+        "traceId": "5b8efff798038103d269b633813fc60c",
+        "spanId": "eee19b7ec3c1b174",
+        "body": {"stringValue": "Example log record"},
+        "attributes": [{"key": "int.attribute", "value": {"intValue": "10"}}]
+      }]
+    }]
+  }]
+}`
+
+func TestOTLPHTTPJSONTraceContext(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantTrace  string
+		wantSpan   string
+	}{
+		{
+			name:       "hex trace context",
+			body:       otlpJSONLogs,
+			wantStatus: http.StatusOK,
+			wantTrace:  "5b8efff798038103d269b633813fc60c",
+			wantSpan:   "eee19b7ec3c1b174",
+		},
+		{
+			name:       "no trace context",
+			body:       strings.NewReplacer(`"traceId": "5b8efff798038103d269b633813fc60c",`, "", `"spanId": "eee19b7ec3c1b174",`, "").Replace(otlpJSONLogs),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid hex",
+			body:       strings.Replace(otlpJSONLogs, "eee19b7ec3c1b174", "not-hex", 1),
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, batchChannel := newTestListener(t)
+			otlpListener := &OTLPPortListener{datasetID: "otlp", format: FormatRaw, jsonMode: JSONModeNormalize}
+
+			req := httptest.NewRequest(http.MethodPost, otlpLogsPath, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", otlpContentTypeJSON)
+			rec := httptest.NewRecorder()
+			l.otlpHTTPHandler(otlpListener)(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if len(batchChannel) != 1 {
+				t.Fatalf("queued %d records, want 1", len(batchChannel))
+			}
+
+			var record struct {
+				TraceID string `json:"trace_id"`
+				SpanID  string `json:"span_id"`
+				Body    string `json:"body"`
+			}
+			msg := <-batchChannel
+			if err := json.Unmarshal(msg.Data, &record); err != nil {
+				t.Fatalf("invalid record %s: %v", msg.Data, err)
+			}
+			if record.TraceID != tt.wantTrace || record.SpanID != tt.wantSpan {
+				t.Errorf("trace context = %q/%q, want %q/%q", record.TraceID, record.SpanID, tt.wantTrace, tt.wantSpan)
+			}
+			if record.Body != "Example log record" {
+				t.Errorf("body = %q, want %q", record.Body, "Example log record")
+			}
+		})
+	}
+}
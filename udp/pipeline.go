@@ -0,0 +1,101 @@
+package udp
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/domain"
+	"github.com/n0needt0/bytefreezer-proxy/pipeline"
+	"github.com/n0needt0/go-goodies/log"
+)
+
+// listenerStages returns a listener's pipeline stages. Text formats that are parsed
+// by the pipeline (cef, leef, kv and csv) get a leading parse stage.
+func listenerStages(udpListener config.UDPListener) []config.Stage {
+	switch format := formatOf(udpListener); format {
+	case FormatCEF, FormatLEEF, FormatKV, FormatCSV:
+		parse := config.Stage{
+			Type:   pipeline.StageParse,
+			Format: format,
+			KV:     udpListener.KV,
+			CSV:    udpListener.CSV,
+		}
+		return append([]config.Stage{parse}, udpListener.Pipeline...)
+	}
+	return udpListener.Pipeline
+}
+
+// applyPipeline runs a listener's stages on a message before it is batched. The
+// stages see the record the forwarder would have written and their result replaces
+// the payload. It returns false when a stage dropped the message.
+func (l *Listener) applyPipeline(pipe *pipeline.Pipeline, msg *domain.UDPMessage) bool {
+	if pipe == nil {
+		return true
+	}
+
+	var record bytes.Buffer
+	l.forwarder.encodeMessage(&record, msg)
+	fields, err := recordFields(record.Bytes(), msg)
+	if err != nil {
+		log.Debugf("Skipping pipeline for message from %s: %v", msg.From, err)
+		return true
+	}
+
+	event := &pipeline.Event{
+		Fields:    fields,
+		TenantID:  msg.TenantID,
+		DatasetID: msg.DatasetID,
+		Timestamp: msg.Timestamp,
+	}
+	if !pipe.Process(event) {
+		l.services.ProxyStats.PipelineDropped++
+		// Dropped on purpose, so acknowledge it
+		if msg.OnBatched != nil {
+			msg.OnBatched()
+		}
+		if msg.OnDelivered != nil {
+			msg.OnDelivered()
+		}
+		return false
+	}
+
+	// Payload text is kept as received rather than HTML-escaped
+	var output bytes.Buffer
+	encoder := json.NewEncoder(&output)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(event.Fields); err != nil {
+		log.Warnf("Failed to encode pipeline output for message from %s: %v", msg.From, err)
+		l.services.ProxyStats.ParseErrors++
+		return true
+	}
+
+	// The payload is now the final JSON record
+	msg.Data = bytes.TrimSuffix(output.Bytes(), []byte("\n"))
+	msg.Format = FormatRaw
+	msg.JSONMode = JSONModeValidateOnly
+	msg.TenantID = event.TenantID
+	msg.DatasetID = event.DatasetID
+	return true
+}
+
+// recordFields decodes an encoded record; JSON payloads that are not objects are
+// wrapped in the usual envelope
+func recordFields(record []byte, msg *domain.UDPMessage) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(record))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	if fields, ok := value.(map[string]interface{}); ok {
+		return fields, nil
+	}
+	return map[string]interface{}{
+		"message":   value,
+		"source":    msg.From,
+		"timestamp": msg.Timestamp.Format(time.RFC3339Nano),
+	}, nil
+}
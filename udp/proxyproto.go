@@ -0,0 +1,194 @@
+package udp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/decoders"
+	"github.com/n0needt0/go-goodies/log"
+)
+
+// proxyHeaderTimeout bounds how long a stream client may take to send its PROXY header
+const proxyHeaderTimeout = 10 * time.Second
+
+// errProxyUntrusted is returned for PROXY protocol peers outside trusted_cidrs
+var errProxyUntrusted = errors.New("proxy protocol sender is not trusted")
+
+// proxyProtocol holds the load balancers allowed to send PROXY protocol headers
+type proxyProtocol struct {
+	trusted []*net.IPNet
+}
+
+// newProxyProtocol parses trusted_cidrs; it returns nil when PROXY protocol is disabled.
+// Plain addresses are accepted as single-host networks.
+func newProxyProtocol(cfg config.ProxyProtocol) (*proxyProtocol, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	p := &proxyProtocol{}
+	for _, cidr := range cfg.TrustedCIDRs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted address %q", cidr)
+			}
+			bits := 8 * len(ip)
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			p.trusted = append(p.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted CIDR %q: %w", cidr, err)
+		}
+		p.trusted = append(p.trusted, network)
+	}
+	return p, nil
+}
+
+// trusts reports whether addr may send PROXY protocol headers
+func (p *proxyProtocol) trusts(addr net.Addr) bool {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.TCPAddr:
+		ip = a.IP
+	default:
+		return false
+	}
+
+	for _, network := range p.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripProxyHeader removes the PROXY protocol v2 header from a datagram and returns
+// the payload with the original client address. Datagrams from untrusted senders
+// or without a valid header are rejected.
+func (l *Listener) stripProxyHeader(portListener *UDPPortListener, data []byte, from net.Addr) ([]byte, net.Addr, bool) {
+	if !portListener.proxyProtocol.trusts(from) {
+		log.Debugf("Rejected datagram from untrusted sender %s on port %d", from, portListener.port)
+		l.services.ProxyStats.ProxyProtocolRejected++
+		return nil, nil, false
+	}
+
+	source, payload, err := decoders.ParseProxyV2(data)
+	if err != nil {
+		log.Debugf("Rejected datagram from %s on port %d: %v", from, portListener.port, err)
+		l.services.ProxyStats.ProxyProtocolRejected++
+		return nil, nil, false
+	}
+
+	if source != nil {
+		from = source
+	}
+	return payload, from, true
+}
+
+// proxyListener wraps a TCP listener whose peers send PROXY protocol v2 headers.
+// It sits below any TLS listener because the header precedes the handshake.
+type proxyListener struct {
+	net.Listener
+	listener       *Listener
+	streamListener *TCPPortListener
+}
+
+// Accept returns connections that read the PROXY header on first use, so a slow
+// client never blocks the accept loop
+func (pl *proxyListener) Accept() (net.Conn, error) {
+	conn, err := pl.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{Conn: conn, proxy: pl}, nil
+}
+
+// proxyConn reports the client address from the PROXY header as its remote address.
+// Reads fail for untrusted peers and invalid headers, which closes the connection.
+type proxyConn struct {
+	net.Conn
+	proxy  *proxyListener
+	once   sync.Once
+	source net.Addr
+	err    error
+
+	mu           sync.Mutex
+	readDeadline time.Time // Last read deadline set by the caller, restored after the header
+}
+
+// SetDeadline records the read deadline so reading the header does not clear it
+func (c *proxyConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetDeadline(t)
+}
+
+// SetReadDeadline records the read deadline so reading the header does not clear it
+func (c *proxyConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetReadDeadline(t)
+}
+
+// readHeader consumes the PROXY header once, before any payload is read
+func (c *proxyConn) readHeader() {
+	c.once.Do(func() {
+		port := c.proxy.streamListener.port
+		peer := c.Conn.RemoteAddr()
+
+		if !c.proxy.streamListener.proxyProtocol.trusts(peer) {
+			c.err = errProxyUntrusted
+		} else {
+			// The header shares any earlier deadline, such as the TLS handshake's
+			c.mu.Lock()
+			previous := c.readDeadline
+			c.mu.Unlock()
+			deadline := time.Now().Add(proxyHeaderTimeout)
+			if !previous.IsZero() && previous.Before(deadline) {
+				deadline = previous
+			}
+
+			c.Conn.SetReadDeadline(deadline)
+			c.source, c.err = decoders.ReadProxyV2(c.Conn)
+			c.Conn.SetReadDeadline(previous)
+		}
+
+		if c.err != nil {
+			log.Warnf("Rejected connection from %s on port %d: %v", peer, port, c.err)
+			c.proxy.listener.services.ProxyStats.ProxyProtocolRejected++
+		}
+	})
+}
+
+// Read returns payload after the PROXY header
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.Conn.Read(b)
+}
+
+// RemoteAddr returns the original client address, or the balancer's for LOCAL headers
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.source != nil {
+		return c.source
+	}
+	return c.Conn.RemoteAddr()
+}
@@ -0,0 +1,59 @@
+package udp
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+)
+
+// proxyV2Header is a PROXY command for a TCP/IPv4 client at 10.1.2.3:4000
+var proxyV2Header = []byte("\r\n\r\n\x00\r\nQUIT\n\x21\x11\x00\x0c" +
+	"\x0a\x01\x02\x03\x7f\x00\x00\x01\x0f\xa0\x02\x02")
+
+func TestProxyConnKeepsDeadline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	proxy, err := newProxyProtocol(config.ProxyProtocol{Enabled: true, TrustedCIDRs: []string{"127.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, _ := newTestListener(t)
+	pl := &proxyListener{Listener: ln, listener: l, streamListener: &TCPPortListener{proxyProtocol: proxy}}
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write(proxyV2Header); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// A deadline set before the header is read, as the TLS handshake does, must
+	// still apply once the header has been consumed
+	conn.SetDeadline(time.Now().Add(100 * time.Millisecond))
+	if got := conn.RemoteAddr().String(); got != "10.1.2.3:4000" {
+		t.Errorf("RemoteAddr() = %s, want 10.1.2.3:4000", got)
+	}
+
+	// Without the deadline the read would block until this closes the connection
+	stop := time.AfterFunc(5*time.Second, func() { conn.Close() })
+	defer stop.Stop()
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Read() error = %v, want deadline exceeded", err)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build linux
+
+package udp
+
+import (
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// socketControl applies interface binding and port reuse before a socket is bound
+func socketControl(bindInterface string, reusePort bool) func(network, address string, rawConn syscall.RawConn) error {
+	return func(network, address string, rawConn syscall.RawConn) error {
+		var sockErr error
+		err := rawConn.Control(func(fd uintptr) {
+			if bindInterface != "" {
+				if err := unix.BindToDevice(int(fd), bindInterface); err != nil {
+					sockErr = fmt.Errorf("failed to bind to interface %s: %w", bindInterface, err)
+					return
+				}
+			}
+			if reusePort {
+				sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return sockErr
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package udp
+
+import (
+	"errors"
+	"syscall"
+)
+
+// socketControl rejects interface binding, which relies on SO_BINDTODEVICE
+func socketControl(bindInterface string, reusePort bool) func(network, address string, rawConn syscall.RawConn) error {
+	return func(network, address string, rawConn syscall.RawConn) error {
+		if bindInterface != "" {
+			return errors.New("interface binding is only supported on Linux")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+package udp
+
+import (
+	"errors"
+	"net"
+
+	"github.com/n0needt0/bytefreezer-proxy/domain"
+	"github.com/n0needt0/bytefreezer-proxy/pipeline"
+	"github.com/n0needt0/go-goodies/log"
+)
+
+// ListenerStatuses reports the runtime state of the listeners: the addresses they
+// are bound to, pipeline stage counters and, for UDP, how many datagrams the kernel
+// dropped because a socket's receive buffer was full
+func (l *Listener) ListenerStatuses() []domain.ListenerStatus {
+	drops, err := kernelDropCounts()
+	if err != nil && !errors.Is(err, errKernelDropsUnsupported) {
+		log.Debugf("Failed to read kernel drop counters: %v", err)
+	}
+
+	statuses := make([]domain.ListenerStatus, 0, len(l.listeners)+len(l.streams)+len(l.otlp))
+	for _, portListener := range l.listeners {
+		conns := udpConns(portListener)
+		status := domain.ListenerStatus{
+			Index:     portListener.index,
+			DatasetID: portListener.datasetID,
+			Port:      portListener.port,
+			Path:      portListener.path,
+			Pipeline:  pipelineStats(portListener.pipeline),
+		}
+		if len(conns) > 0 {
+			// SO_REUSEPORT sockets share one address
+			status.BoundAddresses = []string{conns[0].LocalAddr().String()}
+		} else if portListener.unixConn != nil {
+			status.BoundAddresses = []string{portListener.path}
+		}
+		if drops != nil {
+			if count, ok := socketDrops(conns, drops); ok {
+				status.KernelDrops = &count
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, streamListener := range l.streams {
+		status := domain.ListenerStatus{
+			Index:     streamListener.index,
+			DatasetID: streamListener.datasetID,
+			Port:      streamListener.port,
+			Path:      streamListener.path,
+			Pipeline:  pipelineStats(streamListener.pipeline),
+		}
+		if streamListener.listener != nil {
+			status.BoundAddresses = []string{streamListener.listener.Addr().String()}
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, otlpListener := range l.otlp {
+		status := domain.ListenerStatus{
+			Index:     otlpListener.index,
+			DatasetID: otlpListener.datasetID,
+			Port:      otlpListener.port,
+			Pipeline:  pipelineStats(otlpListener.pipeline),
+		}
+		if otlpListener.boundAddr != "" {
+			status.BoundAddresses = []string{otlpListener.boundAddr}
+		}
+		statuses = append(statuses, status)
+	}
+
+	if l.files != nil {
+		for _, input := range l.files.inputs {
+			statuses = append(statuses, domain.ListenerStatus{
+				Index:     input.index,
+				DatasetID: input.datasetID,
+				Pipeline:  pipelineStats(input.pipeline),
+			})
+		}
+	}
+	return statuses
+}
+
+// pipelineStats returns the stage counters of an optional pipeline
+func pipelineStats(pipe *pipeline.Pipeline) []domain.StageStatus {
+	if pipe == nil {
+		return nil
+	}
+	return pipe.Stats()
+}
+
+// udpConns returns the open UDP sockets of a port
+func udpConns(portListener *UDPPortListener) []*net.UDPConn {
+	conns := portListener.batchConns
+	if portListener.conn != nil {
+		conns = append(conns[:len(conns):len(conns)], portListener.conn)
+	}
+	return conns
+}
+
+// socketDrops sums the kernel drop counters of a port's sockets
+func socketDrops(conns []*net.UDPConn, drops map[uint64]int64) (int64, bool) {
+	var total int64
+	found := false
+	for _, conn := range conns {
+		inode, err := socketInode(conn)
+		if err != nil {
+			continue // Closed
+		}
+		if count, ok := drops[inode]; ok {
+			total += count
+			found = true
+		}
+	}
+	return total, found
+}
@@ -0,0 +1,397 @@
+package udp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+	"github.com/n0needt0/bytefreezer-proxy/pipeline"
+	"github.com/n0needt0/go-goodies/log"
+)
+
+// Stream framing modes (RFC 6587)
+const (
+	FramingAuto          = "auto"
+	FramingOctetCounting = "octet_counting"
+	FramingLF            = "lf"
+)
+
+// errFrameTooLarge is returned when a frame exceeds the configured maximum size
+var errFrameTooLarge = errors.New("frame exceeds maximum size")
+
+// Stream listener protocols
+const (
+	ProtocolTCP        = "tcp"
+	ProtocolTLS        = "tls"
+	ProtocolForward    = "forward"
+	ProtocolLumberjack = "lumberjack"
+)
+
+// isStreamProtocol reports whether a listener protocol is connection oriented
+func isStreamProtocol(protocol string) bool {
+	switch strings.ToLower(protocol) {
+	case ProtocolTCP, ProtocolTLS, ProtocolForward, ProtocolLumberjack, ProtocolUnix:
+		return true
+	}
+	return false
+}
+
+// tlsHandshakeTimeout bounds how long a client may take to complete the TLS handshake
+const tlsHandshakeTimeout = 10 * time.Second
+
+// TCPPortListener represents a single TCP or TLS stream listener
+type TCPPortListener struct {
+	index      int // Position in udp.listeners
+	port       int
+	protocol   string
+	tenantID   string
+	datasetID  string
+	framing    string
+	format     string
+	jsonMode   string
+	pipeline   *pipeline.Pipeline
+	tls        config.TLS
+	tagRules   []config.TagRule
+	lumberjack config.Lumberjack
+	addr       *net.TCPAddr
+	listener   net.Listener
+
+	bindInterface string         // Optional SO_BINDTODEVICE interface
+	proxyProtocol *proxyProtocol // Optional: expect PROXY protocol v2 headers
+
+	path        string // Unix only: socket path
+	socketMode  string
+	socketOwner string
+}
+
+// newTCPPortListener creates a stream listener from its configuration entry
+func newTCPPortListener(udpListener config.UDPListener, tenantID string, ip net.IP) *TCPPortListener {
+	framing := strings.ToLower(udpListener.Framing)
+	if framing == "" {
+		framing = FramingAuto
+	}
+
+	return &TCPPortListener{
+		port:       udpListener.Port,
+		protocol:   strings.ToLower(udpListener.Protocol),
+		tenantID:   tenantID,
+		datasetID:  udpListener.DatasetID,
+		framing:    framing,
+		format:     formatOf(udpListener),
+		jsonMode:   jsonModeOf(udpListener),
+		tls:        udpListener.TLS,
+		tagRules:   udpListener.TagRules,
+		lumberjack: udpListener.Lumberjack,
+		addr: &net.TCPAddr{
+			IP:   ip,
+			Port: udpListener.Port,
+		},
+		bindInterface: udpListener.Interface,
+		path:          udpListener.Path,
+		socketMode:    udpListener.SocketMode,
+		socketOwner:   udpListener.SocketOwner,
+	}
+}
+
+// startStreamListener opens the TCP socket and starts accepting connections
+func (l *Listener) startStreamListener(streamListener *TCPPortListener) error {
+	var serverTLSConfig *tls.Config
+	if streamListener.protocol == ProtocolTLS {
+		var err error
+		serverTLSConfig, err = newServerTLSConfig(streamListener.tls)
+		if err != nil {
+			return fmt.Errorf("failed to configure TLS for port %d: %w", streamListener.port, err)
+		}
+	}
+
+	var listener net.Listener
+	var err error
+	if streamListener.protocol == ProtocolUnix {
+		listener, err = listenUnix(streamListener.path, streamListener.socketMode, streamListener.socketOwner)
+		if err != nil {
+			return err
+		}
+	} else {
+		listener, err = listenConfig(streamListener.bindInterface, false).Listen(context.Background(), "tcp", streamListener.addr.String())
+		if err != nil {
+			return fmt.Errorf("failed to listen on TCP %s: %w", streamListener.addr.String(), err)
+		}
+	}
+	streamListener.listener = listener
+	if streamListener.proxyProtocol != nil {
+		streamListener.listener = &proxyListener{Listener: listener, listener: l, streamListener: streamListener}
+	}
+	if serverTLSConfig != nil {
+		streamListener.listener = tls.NewListener(streamListener.listener, serverTLSConfig)
+	}
+
+	log.Info(strings.ToUpper(streamListener.protocol) + " server listening on " + listener.Addr().String() +
+		" (tenant: " + streamListener.tenantID + ", dataset: " + streamListener.datasetID +
+		", framing: " + streamListener.framing + ")")
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		l.acceptConnections(streamListener)
+	}()
+
+	return nil
+}
+
+// acceptConnections accepts stream connections until the listener is closed
+func (l *Listener) acceptConnections(streamListener *TCPPortListener) {
+	for {
+		conn, err := streamListener.listener.Accept()
+		if err != nil {
+			if l.isClosedConnError(err) {
+				return
+			}
+
+			select {
+			case <-l.quit:
+				return
+			default:
+			}
+
+			log.Errorf("TCP accept error on port %d: %v", streamListener.port, err)
+			l.services.ProxyStats.UDPMessageErrors++
+			continue
+		}
+
+		if streamListener.protocol == ProtocolUnix {
+			conn = &unixPeerConn{Conn: conn, addr: &net.UnixAddr{Name: streamListener.path, Net: ProtocolUnix}}
+		}
+
+		if !l.trackConn(conn) {
+			conn.Close()
+			return
+		}
+
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			defer l.untrackConn(conn)
+			l.handleStream(conn, streamListener)
+		}()
+	}
+}
+
+// handleStream reads frames from a single stream connection
+func (l *Listener) handleStream(conn net.Conn, streamListener *TCPPortListener) {
+	log.Debugf("Accepted %s connection from %s on port %d", streamListener.protocol, conn.RemoteAddr(), streamListener.port)
+
+	tenantID := streamListener.tenantID
+	datasetID := streamListener.datasetID
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		tlsConn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			log.Warnf("TLS handshake with %s on port %d failed: %v", conn.RemoteAddr(), streamListener.port, err)
+			l.services.ProxyStats.UDPMessageErrors++
+			return
+		}
+		tlsConn.SetDeadline(time.Time{})
+
+		state := tlsConn.ConnectionState()
+		if len(state.PeerCertificates) > 0 {
+			// Unverified certificates (client_auth request without a CA) are never mapped
+			verified := len(state.VerifiedChains) > 0
+			var matched bool
+			if verified {
+				tenantID, datasetID, matched = resolveIdentity(streamListener.tls.IdentityMappings,
+					state.PeerCertificates[0], tenantID, datasetID)
+			}
+			log.Debugf("TLS client %s presented certificate CN=%s (verified: %t, mapped: %t, tenant: %s, dataset: %s)",
+				conn.RemoteAddr(), state.PeerCertificates[0].Subject.CommonName, verified, matched, tenantID, datasetID)
+		}
+	}
+
+	if streamListener.protocol == ProtocolForward {
+		l.handleForwardStream(conn, streamListener, tenantID)
+		return
+	}
+	if streamListener.protocol == ProtocolLumberjack {
+		l.handleLumberjackStream(conn, streamListener, tenantID, datasetID)
+		return
+	}
+
+	reader := bufio.NewReader(conn)
+	for {
+		frame, err := readFrame(reader, streamListener.framing, l.config.UDP.MaxFrameBytes)
+		if err != nil {
+			if errors.Is(err, errFrameTooLarge) {
+				log.Warnf("Dropping oversized frame from %s on port %d", conn.RemoteAddr(), streamListener.port)
+				l.services.ProxyStats.UDPMessageErrors++
+				continue
+			}
+
+			if errors.Is(err, io.EOF) || l.isClosedConnError(err) {
+				log.Debugf("Stream connection from %s closed", conn.RemoteAddr())
+				return
+			}
+
+			log.Errorf("Stream read error from %s on port %d: %v", conn.RemoteAddr(), streamListener.port, err)
+			l.services.ProxyStats.UDPMessageErrors++
+			return
+		}
+
+		l.processMessageWithContext(frame, conn.RemoteAddr(), tenantID, datasetID, streamListener.format, streamListener.jsonMode, streamListener.pipeline)
+	}
+}
+
+// trackConn registers an open connection so it can be closed on shutdown
+func (l *Listener) trackConn(conn net.Conn) bool {
+	l.connsMu.Lock()
+	defer l.connsMu.Unlock()
+
+	select {
+	case <-l.quit:
+		return false
+	default:
+	}
+
+	l.conns[conn] = struct{}{}
+	return true
+}
+
+// untrackConn closes and forgets a connection
+func (l *Listener) untrackConn(conn net.Conn) {
+	l.connsMu.Lock()
+	delete(l.conns, conn)
+	l.connsMu.Unlock()
+	conn.Close()
+}
+
+// closeConns closes all tracked connections
+func (l *Listener) closeConns() {
+	l.connsMu.Lock()
+	defer l.connsMu.Unlock()
+
+	for conn := range l.conns {
+		conn.Close()
+	}
+}
+
+// maxOctetCountDigits bounds the MSG-LEN peeked in auto framing mode
+const maxOctetCountDigits = 10
+
+// readFrame reads a single frame using RFC 6587 octet-counting or LF-delimited framing.
+// In auto mode a frame is octet-counted only when it starts with "MSG-LEN SP <".
+func readFrame(reader *bufio.Reader, framing string, maxBytes int) ([]byte, error) {
+	switch framing {
+	case FramingOctetCounting:
+		return readOctetCountedFrame(reader, maxBytes)
+	case FramingLF:
+		return readLFFrame(reader, maxBytes)
+	}
+
+	if _, err := reader.Peek(1); err != nil {
+		return nil, err
+	}
+	if isOctetCounted(reader) {
+		return readOctetCountedFrame(reader, maxBytes)
+	}
+	return readLFFrame(reader, maxBytes)
+}
+
+// isOctetCounted peeks for a non-zero MSG-LEN followed by a space and the "<" of a
+// syslog PRI. It stops at the first byte that rules the header out, so it never
+// waits for data beyond the current frame.
+func isOctetCounted(reader *bufio.Reader) bool {
+	for n := 1; n <= maxOctetCountDigits+2; n++ {
+		peeked, err := reader.Peek(n)
+		if err != nil {
+			return false
+		}
+
+		c := peeked[n-1]
+		switch {
+		case n == 1:
+			if c < '1' || c > '9' {
+				return false
+			}
+		case c >= '0' && c <= '9' && n <= maxOctetCountDigits:
+		case c == ' ':
+			next, err := reader.Peek(n + 1)
+			return err == nil && next[n] == '<'
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// readOctetCountedFrame reads a "MSG-LEN SP SYSLOG-MSG" frame
+func readOctetCountedFrame(reader *bufio.Reader, maxBytes int) ([]byte, error) {
+	header, err := reader.ReadSlice(' ')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return nil, fmt.Errorf("invalid octet-counting header")
+		}
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(string(header[:len(header)-1]))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid octet-counting length %q", header[:len(header)-1])
+	}
+
+	if maxBytes > 0 && length > maxBytes {
+		if _, err := reader.Discard(length); err != nil {
+			return nil, err
+		}
+		return nil, errFrameTooLarge
+	}
+
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	return frame, nil
+}
+
+// readLFFrame reads a newline-delimited frame
+func readLFFrame(reader *bufio.Reader, maxBytes int) ([]byte, error) {
+	var frame bytes.Buffer
+	tooLarge := false
+
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLarge {
+			frame.Write(chunk)
+			if maxBytes > 0 && frame.Len() > maxBytes+1 {
+				tooLarge = true
+				frame.Reset()
+			}
+		}
+
+		if err == nil {
+			break
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if errors.Is(err, io.EOF) && frame.Len() > 0 {
+			// Deliver the final unterminated frame
+			break
+		}
+		return nil, err
+	}
+
+	if tooLarge {
+		return nil, errFrameTooLarge
+	}
+	return frame.Bytes(), nil
+}
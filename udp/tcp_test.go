@@ -0,0 +1,97 @@
+package udp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		framing  string
+		input    string
+		maxBytes int
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:    "auto octet-counted",
+			framing: FramingAuto,
+			input:   "10 <34>1 - hi11 <34>1 - bye",
+			want:    []string{"<34>1 - hi", "<34>1 - bye"},
+		},
+		{
+			name:    "auto lf",
+			framing: FramingAuto,
+			input:   "<34>first\n<34>second\n",
+			want:    []string{"<34>first\n", "<34>second\n"},
+		},
+		{
+			name:    "auto lf starting with a date",
+			framing: FramingAuto,
+			input:   "2026-01-01 started\n",
+			want:    []string{"2026-01-01 started\n"},
+		},
+		{
+			name:    "auto lf starting with a count",
+			framing: FramingAuto,
+			input:   "5 apples\n12 \n42",
+			want:    []string{"5 apples\n", "12 \n", "42"},
+		},
+		{
+			name:    "auto too many digits",
+			framing: FramingAuto,
+			input:   "12345678901 <34>x\n",
+			want:    []string{"12345678901 <34>x\n"},
+		},
+		{
+			name:    "auto mixed",
+			framing: FramingAuto,
+			input:   "7 <34>abc3 items\n",
+			want:    []string{"<34>abc", "3 items\n"},
+		},
+		{
+			name:    "octet counting rejects text",
+			framing: FramingOctetCounting,
+			input:   "abc def",
+			wantErr: true,
+		},
+		{
+			name:     "auto oversized octet-counted",
+			framing:  FramingAuto,
+			input:    "10 <34>123456",
+			maxBytes: 4,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.input))
+			var got []string
+			for {
+				frame, err := readFrame(reader, tt.framing, tt.maxBytes)
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					if !tt.wantErr {
+						t.Fatalf("readFrame() error = %v", err)
+					}
+					return
+				}
+				got = append(got, string(frame))
+			}
+			if tt.wantErr {
+				t.Fatal("readFrame() error = nil, want an error")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("frames = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
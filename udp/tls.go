@@ -0,0 +1,118 @@
+package udp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/n0needt0/bytefreezer-proxy/config"
+)
+
+// Client authentication modes for TLS listeners
+const (
+	ClientAuthNone    = "none"
+	ClientAuthRequest = "request"
+	ClientAuthRequire = "require"
+)
+
+// newServerTLSConfig builds the server TLS configuration for a stream listener
+func newServerTLSConfig(tlsCfg config.TLS) (*tls.Config, error) {
+	if tlsCfg.CertFile == "" || tlsCfg.KeyFile == "" {
+		return nil, fmt.Errorf("tls listener requires cert_file and key_file")
+	}
+
+	cert, err := tls.LoadX509KeyPair(tlsCfg.CertFile, tlsCfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		ClientAuth:   tls.NoClientCert,
+	}
+
+	if tlsCfg.CAFile != "" {
+		caPEM, err := os.ReadFile(tlsCfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file %s: %w", tlsCfg.CAFile, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", tlsCfg.CAFile)
+		}
+		serverConfig.ClientCAs = pool
+	}
+
+	// Mappings grant another tenant or dataset, so they need certificates the proxy verifies
+	if len(tlsCfg.IdentityMappings) > 0 && serverConfig.ClientCAs == nil {
+		return nil, fmt.Errorf("identity_mappings need a ca_file")
+	}
+
+	switch strings.ToLower(tlsCfg.ClientAuth) {
+	case "", ClientAuthNone:
+	case ClientAuthRequest:
+		if serverConfig.ClientCAs != nil {
+			serverConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		} else {
+			serverConfig.ClientAuth = tls.RequestClientCert
+		}
+	case ClientAuthRequire:
+		if serverConfig.ClientCAs == nil {
+			return nil, fmt.Errorf("client_auth require needs a ca_file")
+		}
+		serverConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unknown client_auth mode %q", tlsCfg.ClientAuth)
+	}
+
+	return serverConfig, nil
+}
+
+// certificateIdentities returns the CN and all SANs of a client certificate
+func certificateIdentities(cert *x509.Certificate) []string {
+	identities := make([]string, 0, 1+len(cert.DNSNames)+len(cert.EmailAddresses)+len(cert.URIs)+len(cert.IPAddresses))
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	identities = append(identities, cert.DNSNames...)
+	identities = append(identities, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		identities = append(identities, ip.String())
+	}
+	return identities
+}
+
+// resolveIdentity maps a verified client certificate to a tenant/dataset override.
+// The first configured mapping that matches the CN or any SAN wins; unset
+// fields fall back to the listener defaults. Callers must only pass certificates
+// that chain to the configured CA.
+func resolveIdentity(mappings []config.TLSIdentityMapping, cert *x509.Certificate, tenantID, datasetID string) (string, string, bool) {
+	if cert == nil || len(mappings) == 0 {
+		return tenantID, datasetID, false
+	}
+
+	identities := certificateIdentities(cert)
+	for _, mapping := range mappings {
+		for _, identity := range identities {
+			if !strings.EqualFold(mapping.Identity, identity) {
+				continue
+			}
+			if mapping.TenantID != "" {
+				tenantID = mapping.TenantID
+			}
+			if mapping.DatasetID != "" {
+				datasetID = mapping.DatasetID
+			}
+			return tenantID, datasetID, true
+		}
+	}
+
+	return tenantID, datasetID, false
+}
@@ -0,0 +1,222 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/n0needt0/go-goodies/log"
+)
+
+// Unix domain socket listener protocols
+const (
+	ProtocolUnix     = "unix"     // Stream socket, framed like TCP
+	ProtocolUnixgram = "unixgram" // Datagram socket, one message per datagram
+)
+
+// isUnixProtocol reports whether a listener protocol uses a unix domain socket
+func isUnixProtocol(protocol string) bool {
+	switch strings.ToLower(protocol) {
+	case ProtocolUnix, ProtocolUnixgram:
+		return true
+	}
+	return false
+}
+
+// defaultSocketMode is applied to socket files when no socket_mode is configured
+const defaultSocketMode os.FileMode = 0660
+
+// unixPeerConn reports the socket path as the remote address, since local
+// clients are usually unnamed
+type unixPeerConn struct {
+	net.Conn
+	addr *net.UnixAddr
+}
+
+func (c *unixPeerConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// listenUnix creates a unix stream listener. The socket file is removed on close.
+func listenUnix(path, mode, owner string) (*net.UnixListener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.ListenUnix(ProtocolUnix, &net.UnixAddr{Name: path, Net: ProtocolUnix})
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on unix socket %s: %w", path, err)
+	}
+
+	if err := setSocketPermissions(path, mode, owner); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// listenUnixgram creates a unix datagram socket. The caller removes the socket file on close.
+func listenUnixgram(path, mode, owner string) (*net.UnixConn, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUnixgram(ProtocolUnixgram, &net.UnixAddr{Name: path, Net: ProtocolUnixgram})
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on unixgram socket %s: %w", path, err)
+	}
+
+	if err := setSocketPermissions(path, mode, owner); err != nil {
+		conn.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// removeStaleSocket removes a socket file left behind by a previous run.
+// Existing files that are not sockets are never removed.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	return os.Remove(path)
+}
+
+// setSocketPermissions applies the configured octal mode and "user[:group]" owner
+func setSocketPermissions(path, mode, owner string) error {
+	fileMode := defaultSocketMode
+	if mode != "" {
+		parsed, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || parsed > 0777 {
+			return fmt.Errorf("invalid socket_mode %q for %s", mode, path)
+		}
+		fileMode = os.FileMode(parsed)
+	}
+	if err := os.Chmod(path, fileMode); err != nil {
+		return fmt.Errorf("failed to chmod %s: %w", path, err)
+	}
+
+	if owner == "" {
+		return nil
+	}
+	uid, gid, err := lookupOwner(owner)
+	if err != nil {
+		return fmt.Errorf("invalid socket_owner %q for %s: %w", owner, path, err)
+	}
+	if err := os.Chown(path, uid, gid); err != nil {
+		return fmt.Errorf("failed to chown %s: %w", path, err)
+	}
+	return nil
+}
+
+// lookupOwner resolves "user[:group]" (names or numeric IDs); -1 leaves an ID unchanged
+func lookupOwner(owner string) (int, int, error) {
+	userName, groupName, _ := strings.Cut(owner, ":")
+	uid, gid := -1, -1
+
+	if userName != "" {
+		id, err := strconv.Atoi(userName)
+		if err != nil {
+			u, lookupErr := user.Lookup(userName)
+			if lookupErr != nil {
+				return 0, 0, lookupErr
+			}
+			id, _ = strconv.Atoi(u.Uid)
+		}
+		uid = id
+	}
+
+	if groupName != "" {
+		id, err := strconv.Atoi(groupName)
+		if err != nil {
+			g, lookupErr := user.LookupGroup(groupName)
+			if lookupErr != nil {
+				return 0, 0, lookupErr
+			}
+			id, _ = strconv.Atoi(g.Gid)
+		}
+		gid = id
+	}
+
+	return uid, gid, nil
+}
+
+// startUnixgramListener opens a unix datagram socket and starts reading from it
+func (l *Listener) startUnixgramListener(portListener *UDPPortListener) error {
+	conn, err := listenUnixgram(portListener.path, portListener.socketMode, portListener.socketOwner)
+	if err != nil {
+		return err
+	}
+	portListener.unixConn = conn
+
+	if err := conn.SetReadBuffer(l.config.UDP.ReadBufferSizeBytes); err != nil {
+		log.Warnf("Failed to set read buffer for %s: %v", portListener.path, err)
+	}
+
+	log.Info("Unixgram server listening on " + portListener.path + " (tenant: " + portListener.tenantID +
+		", dataset: " + portListener.datasetID + ")")
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		l.handleMessagesForUnixgram(portListener)
+	}()
+	return nil
+}
+
+// handleMessagesForUnixgram handles incoming datagrams on a unix datagram socket
+func (l *Listener) handleMessagesForUnixgram(portListener *UDPPortListener) {
+	socketAddr := &net.UnixAddr{Name: portListener.path, Net: ProtocolUnixgram}
+	defer l.flushMultiline(portListener)
+
+	for {
+		select {
+		case <-l.quit:
+			return
+		default:
+		}
+
+		portListener.unixConn.SetReadDeadline(time.Now().Add(1 * time.Second))
+
+		buf := l.allocateBuffer()
+		readLen, remoteAddr, err := portListener.unixConn.ReadFromUnix(buf)
+
+		if err != nil {
+			l.deallocateBuffer(buf)
+
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				l.expireListenerState(portListener)
+				continue
+			}
+
+			if l.isClosedConnError(err) {
+				return
+			}
+
+			log.Errorf("Unixgram read error on %s: %v", portListener.path, err)
+			l.services.ProxyStats.UDPMessageErrors++
+			continue
+		}
+
+		// Local senders are usually unbound, so fall back to the socket path
+		var from net.Addr = socketAddr
+		if remoteAddr != nil && remoteAddr.Name != "" {
+			from = remoteAddr
+		}
+
+		l.dispatchDatagram(portListener, l.datagramPayload(buf, readLen, from), from)
+		l.deallocateBuffer(buf)
+	}
+}